@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const (
+	accessibilityCheckAttempts = 10
+	accessibilityCheckInterval = time.Duration(2) * time.Second
+)
+
+// accessibilityServicePattern matches a service component name, like com.example/.TestService.
+var accessibilityServicePattern = regexp.MustCompile(`^[A-Za-z0-9_.]+/[A-Za-z0-9_.$]+$`)
+
+// parseAccessibilityServices parses the comma or newline separated list of service components.
+func parseAccessibilityServices(list string) ([]string, error) {
+	var services []string
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !accessibilityServicePattern.MatchString(item) {
+			return nil, fmt.Errorf("invalid accessibility service (%s), expected a component name like com.example/.TestService", item)
+		}
+		services = append(services, item)
+	}
+	return services, nil
+}
+
+// enableAccessibilityServices enables the services and waits until the accessibility manager runs all of them.
+// The services have to be installed, so it runs after the APK installation.
+func enableAccessibilityServices(adbClient emulatormanager.ADB, serial string, services []string) error {
+	if _, err := adbClient.Shell(serial, "settings", "put", "secure", "enabled_accessibility_services", strings.Join(services, ":")); err != nil {
+		return err
+	}
+	if _, err := adbClient.Shell(serial, "settings", "put", "secure", "accessibility_enabled", "1"); err != nil {
+		return err
+	}
+
+	var inactive []string
+	for i := 0; i < accessibilityCheckAttempts; i++ {
+		out, err := adbClient.Shell(serial, "dumpsys", "accessibility")
+		if err != nil {
+			return err
+		}
+		inactive = inactiveAccessibilityServices(out, services)
+		if len(inactive) == 0 {
+			return nil
+		}
+		log.Debugf("Accessibility services not running yet: %s", strings.Join(inactive, ", "))
+		time.Sleep(accessibilityCheckInterval)
+	}
+	return fmt.Errorf("accessibility services did not start after %d checks: %s", accessibilityCheckAttempts, strings.Join(inactive, ", "))
+}
+
+// inactiveAccessibilityServices returns the services which are missing from the enabled services of the dumpsys accessibility output,
+// or are listed among its crashed services. dumpsys lists the full class names, so the short form (com.example/.TestService) is expanded.
+func inactiveAccessibilityServices(dumpsys string, services []string) []string {
+	var enabled, crashed string
+	for _, line := range strings.Split(dumpsys, "\n") {
+		if strings.Contains(line, "Enabled services:") {
+			enabled += line + "\n"
+		} else if strings.Contains(line, "Crashed services:") {
+			crashed += line + "\n"
+		}
+	}
+
+	var inactive []string
+	for _, service := range services {
+		parts := strings.SplitN(service, "/", 2)
+		fullName := service
+		if strings.HasPrefix(parts[1], ".") {
+			fullName = parts[0] + "/" + parts[0] + parts[1]
+		}
+		if !strings.Contains(enabled, fullName) || strings.Contains(crashed, fullName) {
+			inactive = append(inactive, service)
+		}
+	}
+	return inactive
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const (
+	apkInstallAttempts      = 3
+	apkInstallTimeout       = time.Duration(5) * time.Minute
+	apkInstallRetryInterval = time.Duration(5) * time.Second
+)
+
+// resolveAPKPaths expands the newline separated list of APK paths and glob patterns.
+// Every pattern has to match at least one file, so a typo in the input doesn't go unnoticed.
+func resolveAPKPaths(list string) ([]string, error) {
+	var paths []string
+	for _, pattern := range strings.Split(list, "\n") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern (%s): %s", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no APK found for: %s", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// installAPKs installs the APKs on the device in order, granting all runtime permissions.
+// Each APK is retried, as adb install is known to fail right after the boot while the package manager is still busy.
+func installAPKs(adbClient emulatormanager.ADB, serial string, paths []string) error {
+	for _, pth := range paths {
+		log.Printf("Installing %s (%s)", filepath.Base(pth), serial)
+		if err := installAPK(adbClient, serial, pth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func installAPK(adbClient emulatormanager.ADB, serial, pth string) error {
+	var err error
+	for attempt := 1; attempt <= apkInstallAttempts; attempt++ {
+		var out string
+		out, err = adbClient.RunWithTimeout(serial, apkInstallTimeout, "install", "-r", "-g", pth)
+		// older adb versions exit with 0 even if the installation fails
+		if err == nil && strings.Contains(out, "Failure") {
+			err = fmt.Errorf("adb install failed, output: %s", out)
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt < apkInstallAttempts {
+			log.Warnf("Attempt %d to install %s failed: %s", attempt, pth, err)
+			time.Sleep(apkInstallRetryInterval)
+		}
+	}
+	return fmt.Errorf("failed to install %s after %d attempts: %s", pth, apkInstallAttempts, err)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// avdSpec describes the Android Virtual Device the step creates before starting the emulator.
+type avdSpec struct {
+	name     string
+	apiLevel int
+	tag      string
+	abi      string
+	profile  string
+	// sdcardSize is the size of the SD card created with the AVD, the avdmanager default is used if empty
+	sdcardSize string
+	createArgs []string
+}
+
+// systemImagePackage returns the sdkmanager package path of the system image backing the AVD.
+func (s avdSpec) systemImagePackage() string {
+	return fmt.Sprintf("system-images;android-%d;%s;%s", s.apiLevel, s.tag, s.abi)
+}
+
+// createAVDCommand returns the avdmanager command creating (or overwriting) the AVD, it is killed once ctx is done.
+func createAVDCommand(ctx context.Context, avdManagerPath string, spec avdSpec) *command.Model {
+	args := append([]string{
+		"--verbose", "create", "avd", "--force",
+		"--name", spec.name,
+		"--device", spec.profile,
+		"--package", spec.systemImagePackage(),
+		"--tag", spec.tag,
+		"--abi", spec.abi}, spec.createArgs...)
+	if spec.sdcardSize != "" {
+		args = append(args, "--sdcard", spec.sdcardSize)
+	}
+
+	// hitting no in case it asks for creating hw profile
+	no := strings.Repeat("no\n", 20)
+	return command.NewWithCmd(exec.CommandContext(ctx, avdManagerPath, args...)).SetStdin(strings.NewReader(no))
+}
+
+// avdExists checks if an AVD with the given name has already been created.
+func avdExists(name string) (bool, error) {
+	dir, err := emulatormanager.AVDHome()
+	if err != nil {
+		return false, err
+	}
+	return pathutil.IsPathExists(filepath.Join(dir, name+".ini"))
+}
+
+// createFlagConfigKeys are the config.ini properties written from the avdmanager create flags (and their short forms).
+// The SD card flag is stored as sdcard.size if it is a size and as sdcard.path if it is an image.
+var createFlagConfigKeys = map[string]string{
+	"--device": "hw.device.name",
+	"-d":       "hw.device.name",
+	"--skin":   "skin.name",
+	"-s":       "skin.name",
+	"--sdcard": "sdcard.size",
+	"-c":       "sdcard.size",
+}
+
+// avdConfigMismatches compares the config.ini of an existing AVD with the spec, it returns the properties
+// which don't match (system image, ABI, device profile, SD card and the properties set by the create flags).
+func avdConfigMismatches(spec avdSpec, cfg avdConfig) []string {
+	type property struct {
+		key, value string
+	}
+	expected := []property{
+		{"image.sysdir.1", fmt.Sprintf("system-images/android-%d/%s/%s/", spec.apiLevel, spec.tag, spec.abi)},
+		{"tag.id", spec.tag},
+		{"abi.type", spec.abi},
+		{"hw.device.name", spec.profile},
+	}
+	// the create flags follow the flags of the step, so the last value of a property wins like in avdmanager
+	setExpected := func(key, value string) {
+		for i := range expected {
+			if expected[i].key == key {
+				expected[i].value = value
+				return
+			}
+		}
+		expected = append(expected, property{key, value})
+	}
+	for i := 0; i+1 < len(spec.createArgs); i++ {
+		key, ok := createFlagConfigKeys[spec.createArgs[i]]
+		if !ok {
+			continue
+		}
+		value := spec.createArgs[i+1]
+		if key == "sdcard.size" && !sdcardSizePattern.MatchString(value) {
+			key = "sdcard.path"
+		}
+		setExpected(key, value)
+		i++
+	}
+	if spec.sdcardSize != "" {
+		setExpected("sdcard.size", spec.sdcardSize)
+	}
+
+	var mismatches []string
+	for _, property := range expected {
+		value, _ := cfg.get(property.key)
+		if property.key == "sdcard.size" {
+			// avdmanager may write the size with a space and a byte suffix (512 MB)
+			if sizeMB(strings.TrimSuffix(strings.ReplaceAll(value, " ", ""), "B")) == sizeMB(property.value) {
+				continue
+			}
+		} else if strings.TrimSuffix(value, "/") == strings.TrimSuffix(property.value, "/") {
+			// the system image directory may be written with or without the trailing separator
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s: %s (expected: %s)", property.key, value, property.value))
+	}
+	return mismatches
+}
+
+// existingAVDMismatches returns the properties of the existing AVD which don't match the spec.
+func existingAVDMismatches(spec avdSpec) ([]string, error) {
+	pth, err := avdConfigPath(spec.name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := readAVDConfig(pth)
+	if err != nil {
+		return nil, err
+	}
+	return avdConfigMismatches(spec, cfg), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAVDConfigMismatches(t *testing.T) {
+	spec := avdSpec{name: "emulator", apiLevel: 30, tag: "google_apis", abi: "x86_64", profile: "pixel"}
+	matching := map[string]string{
+		"image.sysdir.1": "system-images/android-30/google_apis/x86_64/",
+		"tag.id":         "google_apis",
+		"abi.type":       "x86_64",
+		"hw.device.name": "pixel",
+	}
+	with := func(values map[string]string) avdConfig {
+		cfg := avdConfig{values: map[string]string{}}
+		for key, value := range matching {
+			cfg.set(key, value)
+		}
+		for key, value := range values {
+			cfg.set(key, value)
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name string
+		spec func(s avdSpec) avdSpec
+		cfg  avdConfig
+		want []string
+	}{
+		{name: "matching", cfg: with(nil)},
+		{name: "system image directory without trailing separator", cfg: with(map[string]string{"image.sysdir.1": "system-images/android-30/google_apis/x86_64"})},
+		{
+			name: "other system image",
+			cfg:  with(map[string]string{"image.sysdir.1": "system-images/android-29/google_apis/x86_64/"}),
+			want: []string{"image.sysdir.1: system-images/android-29/google_apis/x86_64/ (expected: system-images/android-30/google_apis/x86_64/)"},
+		},
+		{
+			name: "other profile",
+			cfg:  with(map[string]string{"hw.device.name": "pixel_6"}),
+			want: []string{"hw.device.name: pixel_6 (expected: pixel)"},
+		},
+		{
+			name: "matching SD card size",
+			spec: func(s avdSpec) avdSpec { s.sdcardSize = "512M"; return s },
+			cfg:  with(map[string]string{"sdcard.size": "512 MB"}),
+		},
+		{
+			name: "SD card size in other unit",
+			spec: func(s avdSpec) avdSpec { s.sdcardSize = "1G"; return s },
+			cfg:  with(map[string]string{"sdcard.size": "1024M"}),
+		},
+		{
+			name: "other SD card size",
+			spec: func(s avdSpec) avdSpec { s.sdcardSize = "1G"; return s },
+			cfg:  with(map[string]string{"sdcard.size": "512M"}),
+			want: []string{"sdcard.size: 512M (expected: 1G)"},
+		},
+		{
+			name: "missing SD card",
+			spec: func(s avdSpec) avdSpec { s.sdcardSize = "512M"; return s },
+			cfg:  with(nil),
+			want: []string{"sdcard.size:  (expected: 512M)"},
+		},
+		{
+			name: "create flags override the profile",
+			spec: func(s avdSpec) avdSpec { s.createArgs = []string{"--device", "pixel_6"}; return s },
+			cfg:  with(map[string]string{"hw.device.name": "pixel_6"}),
+		},
+		{
+			name: "skin of the create flags",
+			spec: func(s avdSpec) avdSpec { s.createArgs = []string{"-s", "1080x1920"}; return s },
+			cfg:  with(map[string]string{"skin.name": "720x1280"}),
+			want: []string{"skin.name: 720x1280 (expected: 1080x1920)"},
+		},
+		{
+			name: "SD card image of the create flags",
+			spec: func(s avdSpec) avdSpec { s.createArgs = []string{"--sdcard", "/tmp/sdcard.img"}; return s },
+			cfg:  with(map[string]string{"sdcard.path": "/tmp/sdcard.img"}),
+		},
+		{
+			name: "create flags without config properties",
+			spec: func(s avdSpec) avdSpec { s.createArgs = []string{"--force", "--snapshot"}; return s },
+			cfg:  with(nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := spec
+			if tt.spec != nil {
+				s = tt.spec(s)
+			}
+			if got := avdConfigMismatches(s, tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("avdConfigMismatches() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// avdCacheKey identifies the inputs an AVD was created from, an archive of other inputs is not restored.
+func avdCacheKey(spec avdSpec) string {
+	inputs := fmt.Sprintf("%s|%d|%s|%s|%s|%s|%s", spec.name, spec.apiLevel, spec.tag, spec.abi, spec.profile, spec.sdcardSize, strings.Join(spec.createArgs, " "))
+	hash := sha256.Sum256([]byte(inputs))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// avdCacheArchivePath returns the path of the AVD's archive in the cache directory.
+func avdCacheArchivePath(cacheDir string, spec avdSpec) string {
+	return filepath.Join(cacheDir, fmt.Sprintf("%s-%s.tar.gz", spec.name, avdCacheKey(spec)))
+}
+
+// avdCacheArchivePattern matches the archives of the AVD created from any inputs, but not the archives of the AVDs
+// whose name only starts with the name of the AVD.
+func avdCacheArchivePattern(cacheDir, avdName string) string {
+	return filepath.Join(cacheDir, avdName+"-"+strings.Repeat("[0-9a-f]", 16)+".tar.gz")
+}
+
+// restoreAVDCache extracts the cached archive of the AVD into the AVD home, replacing the existing AVD.
+// It returns false if there is no archive for the current inputs.
+func restoreAVDCache(cacheDir string, spec avdSpec) (bool, error) {
+	archivePath := avdCacheArchivePath(cacheDir, spec)
+	if exists, err := pathutil.IsPathExists(archivePath); err != nil || !exists {
+		return false, err
+	}
+
+	home, err := emulatormanager.AVDHome()
+	if err != nil {
+		return false, err
+	}
+	for _, pth := range []string{filepath.Join(home, spec.name+".avd"), filepath.Join(home, spec.name+".ini")} {
+		if err := os.RemoveAll(pth); err != nil {
+			return false, err
+		}
+	}
+	if err := extractArchive(archivePath, home); err != nil {
+		return false, err
+	}
+	// the archive might come from a build with another AVD home
+	return true, relocateAVDIni(filepath.Join(home, spec.name+".ini"))
+}
+
+// saveAVDCache archives the AVD into the given archive path of the cache directory,
+// replacing the archives of the AVD created from other inputs.
+// The emulator of the AVD has to be stopped, the disk images of a running emulator are written while being archived.
+func saveAVDCache(archivePath, avdName string) error {
+	home, err := emulatormanager.AVDHome()
+	if err != nil {
+		return err
+	}
+	cacheDir := filepath.Dir(archivePath)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	stale, err := filepath.Glob(avdCacheArchivePattern(cacheDir, avdName))
+	if err != nil {
+		return err
+	}
+	tmpPath := archivePath + ".tmp"
+	if err := writeArchive(tmpPath, home, []string{avdName + ".ini", avdName + ".avd"}); err != nil {
+		if removeErr := os.Remove(tmpPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Warnf("Failed to remove %s: %s", tmpPath, removeErr)
+		}
+		return err
+	}
+	for _, pth := range stale {
+		if err := os.Remove(pth); err != nil {
+			log.Warnf("Failed to remove stale AVD archive (%s): %s", pth, err)
+		}
+	}
+	return os.Rename(tmpPath, archivePath)
+}
+
+// writeArchive writes the given entries of the root directory into a gzipped tar archive, skipping the lock files left by the emulator.
+func writeArchive(archivePath, root string, entries []string) error {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", archivePath, err)
+		}
+	}()
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, entry := range entries {
+		err := filepath.Walk(filepath.Join(root, entry), func(pth string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasSuffix(pth, ".lock") {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+
+			name, err := filepath.Rel(root, pth)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(name)
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			return copyFileTo(tarWriter, pth)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func copyFileTo(w io.Writer, pth string) error {
+	file, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// extractArchive extracts a gzipped tar archive written by writeArchive into the root directory.
+func extractArchive(archivePath, root string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", archivePath, err)
+		}
+	}()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		pth := filepath.Join(root, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(pth, filepath.Clean(root)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid entry in %s: %s", archivePath, header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(pth, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+				return err
+			}
+			if err := extractFile(tarReader, pth, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractFile(r io.Reader, pth string, mode os.FileMode) error {
+	file, err := os.OpenFile(pth, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Warnf("Failed to close %s: %s", pth, closeErr)
+		}
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAVDCacheArchivePattern(t *testing.T) {
+	spec := avdSpec{name: "ci", apiLevel: 30, tag: "google_apis", abi: "x86_64", profile: "pixel"}
+	tablet := spec
+	tablet.name = "ci-tablet"
+
+	tests := []struct {
+		name string
+		pth  string
+		want bool
+	}{
+		{name: "archive of the AVD", pth: avdCacheArchivePath("cache", spec), want: true},
+		{name: "archive of the AVD from other inputs", pth: filepath.Join("cache", "ci-0123456789abcdef.tar.gz"), want: true},
+		{name: "archive of an AVD with a longer name", pth: avdCacheArchivePath("cache", tablet)},
+		{name: "temporary archive", pth: avdCacheArchivePath("cache", spec) + ".tmp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filepath.Match(avdCacheArchivePattern("cache", "ci"), tt.pth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("pattern matches %s = %v, want %v", tt.pth, got, tt.want)
+			}
+		})
+	}
+}
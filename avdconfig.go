@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// avdConfig is the content of an AVD's config.ini, keeping the order of the entries, so rewriting the file
+// only changes the updated values.
+type avdConfig struct {
+	keys   []string
+	values map[string]string
+}
+
+// avdConfigPath returns the path of the config.ini of the AVD with the given name.
+func avdConfigPath(name string) (string, error) {
+	dir, err := emulatormanager.AVDHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".avd", "config.ini"), nil
+}
+
+// readAVDConfig parses the key=value lines of config.ini; blank lines and comments are dropped.
+func readAVDConfig(pth string) (avdConfig, error) {
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return avdConfig{}, err
+	}
+
+	cfg := avdConfig{values: map[string]string{}}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.Index(line, "=")
+		if separator < 0 {
+			return avdConfig{}, fmt.Errorf("invalid line in %s: %s", pth, line)
+		}
+		cfg.set(strings.TrimSpace(line[:separator]), strings.TrimSpace(line[separator+1:]))
+	}
+	if err := scanner.Err(); err != nil {
+		return avdConfig{}, err
+	}
+	return cfg, nil
+}
+
+func (c *avdConfig) get(key string) (string, bool) {
+	value, ok := c.values[key]
+	return value, ok
+}
+
+func (c *avdConfig) set(key, value string) {
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.values[key] = value
+}
+
+// update sets a property and logs the change.
+func (c *avdConfig) update(key, value string) {
+	if previous, ok := c.get(key); ok {
+		log.Printf("- %s: %s -> %s", key, previous, value)
+	} else {
+		log.Printf("- %s: %s", key, value)
+	}
+	c.set(key, value)
+}
+
+func (c *avdConfig) setInt(key string, value int) {
+	c.update(key, strconv.Itoa(value))
+}
+
+// setSizeMB sets a disk size property, which are stored with a unit suffix.
+func (c *avdConfig) setSizeMB(key string, megabytes int) {
+	c.update(key, fmt.Sprintf("%dM", megabytes))
+}
+
+// write saves the config in the key=value format the avdmanager uses.
+func (c *avdConfig) write(pth string) error {
+	var b strings.Builder
+	for _, key := range c.keys {
+		b.WriteString(key + "=" + c.values[key] + "\n")
+	}
+	return ioutil.WriteFile(pth, []byte(b.String()), 0644)
+}
+
+// hardwareOverrides are the AVD hardware properties set by the step inputs, zero values are left unchanged.
+type hardwareOverrides struct {
+	ramSizeMB    int
+	vmHeapSizeMB int
+	screenWidth  int
+	screenHeight int
+	density      int
+	// dataPartitionSizeMB is the size of the /data partition
+	dataPartitionSizeMB int
+}
+
+// parseScreenResolution parses the <width>x<height> input.
+func parseScreenResolution(s string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(s), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid screen resolution (%s), expected format: <width>x<height>", s)
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid screen width (%s)", parts[0])
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid screen height (%s)", parts[1])
+	}
+	return width, height, nil
+}
+
+func (o hardwareOverrides) empty() bool {
+	return o == hardwareOverrides{}
+}
+
+// applyHardwareOverrides writes the overrides into the config.ini of the AVD.
+func applyHardwareOverrides(avdName string, overrides hardwareOverrides) error {
+	pth, err := avdConfigPath(avdName)
+	if err != nil {
+		return err
+	}
+	cfg, err := readAVDConfig(pth)
+	if err != nil {
+		return fmt.Errorf("failed to read AVD config: %s", err)
+	}
+
+	if overrides.ramSizeMB > 0 {
+		cfg.setInt("hw.ramSize", overrides.ramSizeMB)
+	}
+	if overrides.vmHeapSizeMB > 0 {
+		cfg.setInt("vm.heapSize", overrides.vmHeapSizeMB)
+	}
+	if overrides.screenWidth > 0 && overrides.screenHeight > 0 {
+		cfg.setInt("hw.lcd.width", overrides.screenWidth)
+		cfg.setInt("hw.lcd.height", overrides.screenHeight)
+	}
+	if overrides.density > 0 {
+		cfg.setInt("hw.lcd.density", overrides.density)
+	}
+	if overrides.dataPartitionSizeMB > 0 {
+		cfg.setSizeMB("disk.dataPartition.size", overrides.dataPartitionSizeMB)
+	}
+
+	if err := cfg.write(pth); err != nil {
+		return fmt.Errorf("failed to write AVD config: %s", err)
+	}
+	return nil
+}
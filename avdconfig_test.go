@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadAVDConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantKeys   []string
+		wantValues map[string]string
+		wantErr    bool
+	}{
+		{
+			name:       "entries",
+			content:    "hw.ramSize=2048\nabi.type=x86_64\n",
+			wantKeys:   []string{"hw.ramSize", "abi.type"},
+			wantValues: map[string]string{"hw.ramSize": "2048", "abi.type": "x86_64"},
+		},
+		{
+			name:       "comments, blank lines and spaces",
+			content:    "# AVD config\n\n hw.lcd.width = 1080 \nimage.sysdir.1=system-images/android-30/google_apis/x86_64/\n",
+			wantKeys:   []string{"hw.lcd.width", "image.sysdir.1"},
+			wantValues: map[string]string{"hw.lcd.width": "1080", "image.sysdir.1": "system-images/android-30/google_apis/x86_64/"},
+		},
+		{
+			name:       "value with separator",
+			content:    "skin.path=_no_skin=1\n",
+			wantKeys:   []string{"skin.path"},
+			wantValues: map[string]string{"skin.path": "_no_skin=1"},
+		},
+		{
+			name:       "repeated key keeps its first position",
+			content:    "hw.ramSize=1536\nabi.type=x86\nhw.ramSize=2048\n",
+			wantKeys:   []string{"hw.ramSize", "abi.type"},
+			wantValues: map[string]string{"hw.ramSize": "2048", "abi.type": "x86"},
+		},
+		{name: "invalid line", content: "hw.ramSize\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pth := filepath.Join(t.TempDir(), "config.ini")
+			if err := ioutil.WriteFile(pth, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readAVDConfig(pth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readAVDConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.keys, tt.wantKeys) {
+				t.Errorf("readAVDConfig() keys = %v, want %v", got.keys, tt.wantKeys)
+			}
+			if !reflect.DeepEqual(got.values, tt.wantValues) {
+				t.Errorf("readAVDConfig() values = %v, want %v", got.values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestReadAVDConfigMissingFile(t *testing.T) {
+	if _, err := readAVDConfig(filepath.Join(t.TempDir(), "config.ini")); err == nil {
+		t.Error("readAVDConfig() of a missing file succeeded")
+	}
+}
+
+func TestAVDConfigWriteKeepsOrder(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "config.ini")
+	if err := ioutil.WriteFile(pth, []byte("hw.ramSize=1536\n# comment\nabi.type=x86_64\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readAVDConfig(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.setInt("hw.ramSize", 4096)
+	cfg.setSizeMB("disk.dataPartition.size", 8192)
+	if err := cfg.write(pth); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "hw.ramSize=4096\nabi.type=x86_64\ndisk.dataPartition.size=8192M\n"
+	if string(content) != want {
+		t.Errorf("written config = %q, want %q", content, want)
+	}
+}
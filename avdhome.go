@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// avdIniPathPattern matches the line of an AVD's .ini file pointing to its .avd directory, like: path=/home/user/.android/avd/emulator.avd
+var avdIniPathPattern = regexp.MustCompile(`(?m)^path=(.*)$`)
+
+// useAVDHome makes avdmanager and the emulator store and look up the AVDs in dir, via ANDROID_AVD_HOME.
+// The AVDs of a restored or pre-provisioned directory might point to the location they were created at,
+// their .ini files are updated to point into dir.
+func useAVDHome(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.Setenv("ANDROID_AVD_HOME", dir); err != nil {
+		return err
+	}
+
+	iniPaths, err := filepath.Glob(filepath.Join(dir, "*.ini"))
+	if err != nil {
+		return err
+	}
+	for _, iniPath := range iniPaths {
+		if err := relocateAVDIni(iniPath); err != nil {
+			log.Warnf("Failed to update AVD (%s): %s", filepath.Base(iniPath), err)
+		}
+	}
+	return nil
+}
+
+// relocateAVDIni points the .ini file to the .avd directory next to it, if the directory it points to doesn't exist.
+func relocateAVDIni(iniPath string) error {
+	content, err := ioutil.ReadFile(iniPath)
+	if err != nil {
+		return err
+	}
+	matches := avdIniPathPattern.FindSubmatch(content)
+	if matches == nil {
+		return nil
+	}
+	if exists, err := pathutil.IsDirExists(strings.TrimSpace(string(matches[1]))); err != nil || exists {
+		return err
+	}
+
+	avdDir := strings.TrimSuffix(iniPath, ".ini") + ".avd"
+	if exists, err := pathutil.IsDirExists(avdDir); err != nil || !exists {
+		return err
+	}
+	log.Printf("Pointing AVD (%s) to %s", filepath.Base(iniPath), avdDir)
+	updated := avdIniPathPattern.ReplaceAllLiteral(content, []byte(fmt.Sprintf("path=%s", avdDir)))
+	return ioutil.WriteFile(iniPath, updated, 0644)
+}
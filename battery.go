@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// batteryDefault leaves the corresponding battery property as the emulator sets it.
+const batteryDefault = "default"
+
+// batteryState is the simulated battery of the device, unset properties are left unchanged.
+type batteryState struct {
+	// level is the remaining charge in percent, -1 if unset
+	level    int
+	charging string
+	health   string
+}
+
+func (b batteryState) isSet() bool {
+	return b.level >= 0 || b.charging != batteryDefault || b.health != batteryDefault
+}
+
+// parseBatteryLevel parses the battery level input, an empty value leaves the level unchanged.
+func parseBatteryLevel(s string) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+	level, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if err != nil || level < 0 || level > 100 {
+		return 0, fmt.Errorf("invalid battery level (%s), expected a percentage between 0 and 100", s)
+	}
+	return level, nil
+}
+
+// setBatteryState returns a post-boot action which sets the battery through the emulator console (power commands).
+// The AC charger is connected while charging and when the battery is full, and disconnected otherwise.
+func setBatteryState(battery batteryState) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(_ emulatormanager.ADB, serial string) error {
+		client, err := emuconsole.DialSerial(serial)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				log.Warnf("Failed to close emulator console connection: %s", err)
+			}
+		}()
+
+		if battery.charging != batteryDefault {
+			// the charger has to be set first, connecting it changes the status
+			if err := client.SetACCharger(battery.charging == "charging" || battery.charging == "full"); err != nil {
+				return err
+			}
+			if err := client.SetBatteryStatus(battery.charging); err != nil {
+				return err
+			}
+		}
+		if battery.level >= 0 {
+			if err := client.SetBatteryCapacity(battery.level); err != nil {
+				return err
+			}
+		}
+		if battery.health != batteryDefault {
+			return client.SetBatteryHealth(battery.health)
+		}
+		return nil
+	}
+}
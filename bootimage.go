@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// bootImageFlag returns the emulator flag starting the device with the given kernel or ramdisk image
+// instead of the one of the system image, the image has to be an existing file.
+func bootImageFlag(flag, pth string) ([]string, error) {
+	if pth == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(pth)
+	if err != nil {
+		return nil, fmt.Errorf("image not accessible: %s", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("image is a directory: %s", pth)
+	}
+	return []string{flag, pth}, nil
+}
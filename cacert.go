@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const systemCACertsDir = "/system/etc/security/cacerts"
+
+// apexCACertsAPILevel is the first API level which loads the system certificates from the Conscrypt APEX module
+// instead of systemCACertsDir.
+const apexCACertsAPILevel = 34
+
+// validateCACertificateAPILevel fails on the API levels which ignore the certificates of systemCACertsDir.
+func validateCACertificateAPILevel(apiLevel int) error {
+	if apiLevel >= apexCACertsAPILevel {
+		return fmt.Errorf("not supported on API level %d and above (requested: %d), the system certificates are loaded from the Conscrypt APEX module", apexCACertsAPILevel, apiLevel)
+	}
+	return nil
+}
+
+// caCertificateName returns the file name Android looks up the certificate by in the system store:
+// the OpenSSL subject hash (in the pre-1.0 format) with a .0 suffix.
+func caCertificateName(pemPath string) (string, error) {
+	cmd := command.New("openssl", "x509", "-inform", "PEM", "-subject_hash_old", "-noout", "-in", pemPath)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+	return out + ".0", nil
+}
+
+// installCACertificate adds the certificate to the system certificate store of the device and reboots it,
+// so the certificate is trusted by every app. The emulator has to be started with the -writable-system flag.
+func installCACertificate(ctx context.Context, adbClient emulatormanager.ADB, serial, pemPath string, boot emulatormanager.BootCompletion) error {
+	name, err := caCertificateName(pemPath)
+	if err != nil {
+		return err
+	}
+
+	if err := emulatormanager.WaitForBootComplete(ctx, adbClient, serial, boot); err != nil {
+		return err
+	}
+	// the requested API level is validated up front, this catches the images reporting a different one
+	out, err := adbClient.Shell(serial, "getprop", "ro.build.version.sdk")
+	if err != nil {
+		return fmt.Errorf("failed to read the API level of the device: %s", err)
+	}
+	apiLevel, err := strconv.Atoi(out)
+	if err != nil {
+		return fmt.Errorf("failed to parse the API level of the device (%s): %s", out, err)
+	}
+	if err := validateCACertificateAPILevel(apiLevel); err != nil {
+		return err
+	}
+
+	if err := remountSystem(ctx, adbClient, serial, boot); err != nil {
+		return fmt.Errorf("failed to remount system partition: %s", err)
+	}
+
+	devicePath := systemCACertsDir + "/" + name
+	if _, err := adbClient.Run(serial, "push", pemPath, devicePath); err != nil {
+		return err
+	}
+	if _, err := adbClient.Shell(serial, "chmod", "644", devicePath); err != nil {
+		return err
+	}
+	return rebootDevice(ctx, adbClient, serial, boot)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// leftoverProcessPatterns match the command lines of emulator processes left behind by previous builds,
+// the path of the emulator front-end is separated by a backslash on Windows.
+var leftoverProcessPatterns = []string{"qemu-system", `emulator[\\/]emulator`}
+
+// cleanupEmulators stops the emulators registered in adb, kills the leftover emulator processes
+// and restarts the adb server, so that no zombie device is mistaken for the newly started one.
+func cleanupEmulators(adbClient emulatormanager.ADB, runningDevices map[string]string) error {
+	for serial := range runningDevices {
+		log.Printf("Stopping emulator: %s", serial)
+		if _, err := adbClient.Run(serial, "emu", "kill"); err != nil {
+			log.Warnf("Failed to stop %s: %s", serial, err)
+		}
+	}
+
+	for _, pattern := range leftoverProcessPatterns {
+		if err := emulatormanager.KillProcesses(pattern); err != nil {
+			return fmt.Errorf("failed to kill %s processes: %s", pattern, err)
+		}
+	}
+
+	return adbClient.RestartServer()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// getpropLinePattern matches a line of the getprop output: [key]: [value]
+var getpropLinePattern = regexp.MustCompile(`^\[([^\]]+)\]: \[(.*)\]$`)
+
+// reportProperties are the system properties printed in the device report.
+var reportProperties = []struct{ name, key string }{
+	{"Fingerprint", "ro.build.fingerprint"},
+	{"Android version", "ro.build.version.release"},
+	{"API level", "ro.build.version.sdk"},
+	{"ABI", "ro.product.cpu.abi"},
+}
+
+// deviceReport is the state of the booted device, printed to the log and archived with the full dumps,
+// so the devices of different builds can be compared.
+type deviceReport struct {
+	properties map[string]string
+	storage    string
+	memory     string
+	root       bool
+
+	// sections are the raw outputs the report is built from
+	sections []reportSection
+}
+
+type reportSection struct {
+	title, command, output string
+}
+
+// parseGetprop returns the properties of the getprop output.
+func parseGetprop(out string) map[string]string {
+	properties := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if matches := getpropLinePattern.FindStringSubmatch(strings.TrimSpace(line)); len(matches) == 3 {
+			properties[matches[1]] = matches[2]
+		}
+	}
+	return properties
+}
+
+// meminfoValue returns the value of the key (like MemTotal) from the /proc/meminfo content.
+func meminfoValue(meminfo, key string) string {
+	for _, line := range strings.Split(meminfo, "\n") {
+		if strings.HasPrefix(line, key+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, key+":"))
+		}
+	}
+	return "unknown"
+}
+
+// collectDeviceReport queries the device, a failing query is recorded in the report instead of failing it.
+func collectDeviceReport(adbClient emulatormanager.ADB, serial string) deviceReport {
+	report := deviceReport{}
+	run := func(title string, args ...string) string {
+		out, err := adbClient.Shell(serial, args...)
+		if err != nil {
+			out = fmt.Sprintf("failed: %s", err)
+		}
+		report.sections = append(report.sections, reportSection{title: title, command: strings.Join(args, " "), output: out})
+		return out
+	}
+
+	report.properties = parseGetprop(run("System properties", "getprop"))
+
+	df := strings.Split(run("Storage", "df", "-h", "/data"), "\n")
+	report.storage = strings.Join(strings.Fields(df[len(df)-1]), " ")
+
+	meminfo := run("Memory", "cat", "/proc/meminfo")
+	report.memory = fmt.Sprintf("%s total, %s available", meminfoValue(meminfo, "MemTotal"), meminfoValue(meminfo, "MemAvailable"))
+
+	// root is available either through adbd running as root or through su (userdebug images)
+	report.root = run("Root access (adbd)", "id", "-u") == "0" || run("Root access (su)", "su", "0", "id", "-u") == "0"
+	return report
+}
+
+func (r deviceReport) print(serial string) {
+	log.Printf("Device report (%s):", serial)
+	for _, property := range reportProperties {
+		value := r.properties[property.key]
+		if value == "" {
+			value = "unknown"
+		}
+		log.Printf("- %s: %s", property.name, value)
+	}
+	log.Printf("- Storage (/data): %s", r.storage)
+	log.Printf("- Memory: %s", r.memory)
+	log.Printf("- Root available: %t", r.root)
+}
+
+// write saves the raw outputs of the report into dir.
+func (r deviceReport) write(dir, serial string) (string, error) {
+	var b strings.Builder
+	for _, section := range r.sections {
+		fmt.Fprintf(&b, "=== %s ($ adb -s %s shell %s)\n%s\n\n", section.title, serial, section.command, section.output)
+	}
+	pth := filepath.Join(dir, fmt.Sprintf("emulator-device-report-%s.txt", serial))
+	return pth, ioutil.WriteFile(pth, []byte(b.String()), 0644)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// failureDiagnostics configures which debug artifacts are collected when a device fails to start.
+// Collection failures are only logged, as the diagnostics are collected while the step is already failing.
+type failureDiagnostics struct {
+	dir        string
+	bugreport  bool
+	screenshot bool
+	crashDumps bool
+	// grpc takes the screenshot through the gRPC endpoint of the emulator, falling back to adb
+	grpc bool
+}
+
+// collect saves the enabled artifacts of the device; the screenshot and the crash dumps require the device to be online.
+// The pid of the emulator process is 0 if it is unknown.
+func (d failureDiagnostics) collect(adbClient emulatormanager.ADB, serial string, pid int, online bool) {
+	if serial == "" {
+		return
+	}
+	if d.screenshot && online {
+		if !d.grpc || pid == 0 || !collectScreenshotGRPC(pid, serial, d.dir) {
+			collectScreenshot(adbClient, serial, d.dir)
+		}
+	}
+	if d.crashDumps && online {
+		collectCrashDumps(adbClient, serial, crashDumpsPath(d.dir, serial))
+	}
+	if d.bugreport {
+		collectBugreport(adbClient, serial, d.dir)
+	}
+}
+
+func artifactPath(dir, kind, serial, ext string) string {
+	return filepath.Join(dir, fmt.Sprintf("emulator-%s-%s-%s.%s", kind, serial, time.Now().Format("20060102-150405"), ext))
+}
+
+// crashDumpDirs are the device directories of the native crash tombstones and the ANR traces.
+var crashDumpDirs = []string{"/data/tombstones", "/data/anr"}
+
+// crashDumpsPath returns the directory in dir the crash dumps of the device are pulled into.
+func crashDumpsPath(dir, serial string) string {
+	return filepath.Join(dir, fmt.Sprintf("emulator-crash-dumps-%s-%s", serial, time.Now().Format("20060102-150405")))
+}
+
+// collectCrashDumps pulls the tombstones and the ANR traces of the device into the pth directory.
+func collectCrashDumps(adbClient emulatormanager.ADB, serial, pth string) {
+	log.Printf("Collecting tombstones and ANR traces of %s", serial)
+	if err := os.MkdirAll(pth, 0755); err != nil {
+		log.Warnf("Failed to create crash dumps directory: %s", err)
+		return
+	}
+	pulled := 0
+	for _, dir := range crashDumpDirs {
+		// the directories are only readable with root access on some system images
+		if _, err := adbClient.Run(serial, "pull", dir, pth); err != nil {
+			log.Warnf("Failed to pull %s: %s", dir, err)
+			continue
+		}
+		pulled++
+	}
+	if pulled > 0 {
+		log.Printf("- Crash dumps saved to: %s", pth)
+	}
+}
+
+// collectBugreport saves an adb bugreport of the device into dir.
+func collectBugreport(adbClient emulatormanager.ADB, serial, dir string) {
+	pth := artifactPath(dir, "bugreport", serial, "zip")
+	log.Printf("Collecting bugreport of %s", serial)
+	if _, err := adbClient.Run(serial, "bugreport", pth); err != nil {
+		log.Warnf("Failed to collect bugreport: %s", err)
+		return
+	}
+	log.Printf("- Bugreport saved to: %s", pth)
+}
+
+// collectScreenshot saves the current screen of the device as a PNG into dir.
+func collectScreenshot(adbClient emulatormanager.ADB, serial, dir string) {
+	pth := artifactPath(dir, "screenshot", serial, "png")
+	log.Printf("Taking screenshot of %s", serial)
+
+	screenshot, err := os.Create(pth)
+	if err != nil {
+		log.Warnf("Failed to create screenshot file: %s", err)
+		return
+	}
+	defer func() {
+		if err := screenshot.Close(); err != nil {
+			log.Warnf("Failed to close screenshot file: %s", err)
+		}
+	}()
+
+	cmd := command.New(adbClient.Path, "-s", serial, "exec-out", "screencap", "-p").SetStdout(screenshot)
+	if err := cmd.Run(); err != nil {
+		log.Warnf("Failed to take screenshot: %s", err)
+		return
+	}
+	log.Printf("- Screenshot saved to: %s", pth)
+}
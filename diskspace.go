@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// systemImageDiskMB is the space of a system image download and its extracted content
+	systemImageDiskMB = 4096
+	// avdDiskMB is the space of an AVD with the default data partition, including its snapshots and overlays
+	avdDiskMB = 6144
+)
+
+// diskRequirement is the space needed in the directory.
+type diskRequirement struct {
+	dir       string
+	megabytes int64
+}
+
+// avdDiskRequirement estimates the space the running AVD needs: its data partition (or the default) and its SD card.
+func avdDiskRequirement(dataPartitionSizeMB int, sdcardSize string) int64 {
+	megabytes := int64(avdDiskMB)
+	if dataPartitionSizeMB > 0 {
+		// the data partition is the bulk of the default size
+		megabytes = int64(dataPartitionSizeMB) + avdDiskMB/3
+	}
+	return megabytes + sizeMB(sdcardSize)
+}
+
+// sizeMB converts an avdmanager size (like 512M or 2G) to megabytes, 0 if the size is empty or invalid.
+func sizeMB(size string) int64 {
+	if !sdcardSizePattern.MatchString(size) {
+		return 0
+	}
+	value, err := strconv.ParseInt(size[:len(size)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch size[len(size)-1] {
+	case 'K':
+		return value / 1024
+	case 'G':
+		return value * 1024
+	default:
+		return value
+	}
+}
+
+// existingDir returns the path or its closest existing parent, the AVD home might not exist before the first AVD is created.
+func existingDir(pth string) string {
+	for {
+		if _, err := os.Stat(pth); err == nil || filepath.Dir(pth) == pth {
+			return pth
+		}
+		pth = filepath.Dir(pth)
+	}
+}
+
+// checkDiskSpace fails if the free space of a filesystem is less than the space the directories on it need altogether.
+func checkDiskSpace(requirements []diskRequirement) error {
+	type filesystem struct {
+		dirs             []string
+		required, freeMB int64
+	}
+	filesystems := map[string]*filesystem{}
+	var devices []string
+	for _, requirement := range requirements {
+		dir := existingDir(requirement.dir)
+		device, freeMB, err := filesystemFreeSpace(dir)
+		if err != nil {
+			return fmt.Errorf("failed to get the free space of %s: %s", dir, err)
+		}
+
+		fs, ok := filesystems[device]
+		if !ok {
+			fs = &filesystem{freeMB: freeMB}
+			filesystems[device] = fs
+			devices = append(devices, device)
+		}
+		fs.dirs = append(fs.dirs, requirement.dir)
+		fs.required += requirement.megabytes
+	}
+
+	var problems []string
+	for _, device := range devices {
+		fs := filesystems[device]
+		if fs.freeMB < fs.required {
+			problems = append(problems, fmt.Sprintf("%s: %d MB free, about %d MB needed", strings.Join(fs.dirs, ", "), fs.freeMB, fs.required))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("not enough disk space for the system image downloads and the AVDs, free up space or use a larger machine:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// filesystemFreeSpace returns the ID of the filesystem the directory is on and its free space in megabytes.
+func filesystemFreeSpace(dir string) (string, int64, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", 0, fmt.Errorf("failed to get the filesystem of %s", dir)
+	}
+
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &statfs); err != nil {
+		return "", 0, err
+	}
+	return strconv.FormatUint(uint64(stat.Dev), 10), int64(statfs.Bavail) * int64(statfs.Bsize) / 1024 / 1024, nil
+}
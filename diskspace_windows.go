@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// filesystemFreeSpace returns the volume the directory is on and its free space in megabytes.
+func filesystemFreeSpace(dir string) (string, int64, error) {
+	dirPtr, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return "", 0, err
+	}
+	var freeBytes uint64
+	if ret, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(dirPtr)), uintptr(unsafe.Pointer(&freeBytes)), 0, 0); ret == 0 {
+		return "", 0, err
+	}
+	return strings.ToUpper(filepath.VolumeName(dir)), int64(freeBytes / 1024 / 1024), nil
+}
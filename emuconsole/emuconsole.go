@@ -0,0 +1,243 @@
+// Package emuconsole implements a client for the Android emulator console (the telnet interface of a running emulator).
+//
+// See: https://developer.android.com/studio/run/emulator-console
+package emuconsole
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the time a console command (including the connection) is allowed to take.
+const DefaultTimeout = 10 * time.Second
+
+// Client is an authenticated connection to the console of a running emulator.
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// AuthTokenPath returns the path of the console auth token file generated by the emulator.
+func AuthTokenPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".emulator_console_auth_token"), nil
+}
+
+// ReadAuthToken reads the console auth token generated by the emulator.
+// An empty token means the console doesn't require authentication.
+func ReadAuthToken() (string, error) {
+	pth, err := AuthTokenPath()
+	if err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return "", fmt.Errorf("failed to read auth token: %s", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// PortFromSerial returns the console port of the emulator with the given adb serial (for example emulator-5554).
+func PortFromSerial(serial string) (int, error) {
+	portStr := strings.TrimPrefix(serial, "emulator-")
+	if portStr == serial {
+		return 0, fmt.Errorf("%s is not an emulator serial", serial)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid emulator serial %s: %s", serial, err)
+	}
+	return port, nil
+}
+
+// Dial connects to the console of the emulator listening on the given port and authenticates with authToken.
+func Dial(port int, authToken string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", port), DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to emulator console: %s", err)
+	}
+
+	c := &Client{conn: conn, reader: bufio.NewReader(conn), timeout: DefaultTimeout}
+	// the console sends a banner terminated by OK after connecting
+	if _, err := c.readResponse(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to read console banner: %s", err)
+	}
+
+	if authToken != "" {
+		if _, err := c.Command("auth " + authToken); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to authenticate: %s", err)
+		}
+	}
+
+	return c, nil
+}
+
+// DialSerial connects to the console of the emulator with the given adb serial using the default auth token.
+func DialSerial(serial string) (*Client, error) {
+	port, err := PortFromSerial(serial)
+	if err != nil {
+		return nil, err
+	}
+	token, err := ReadAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	return Dial(port, token)
+}
+
+// Close closes the console connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Command sends a console command and returns its output (without the closing OK line).
+func (c *Client) Command(cmd string) (string, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintf(c.conn, "%s\n", cmd); err != nil {
+		return "", fmt.Errorf("failed to send command (%s): %s", cmd, err)
+	}
+
+	out, err := c.readResponse()
+	if err != nil {
+		return out, fmt.Errorf("command (%s) failed: %s", cmd, err)
+	}
+	return out, nil
+}
+
+// readResponse reads the console output until the OK or KO status line.
+func (c *Client) readResponse() (string, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return strings.Join(lines, "\n"), err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "OK":
+			return strings.Join(lines, "\n"), nil
+		case strings.HasPrefix(line, "KO"):
+			return strings.Join(lines, "\n"), errors.New(strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "KO"), ":")))
+		default:
+			lines = append(lines, line)
+		}
+	}
+}
+
+// AVDStatus returns the status of the virtual device (for example: virtual device is running).
+func (c *Client) AVDStatus() (string, error) {
+	return c.Command("avd status")
+}
+
+// AVDName returns the name of the AVD the emulator runs.
+func (c *Client) AVDName() (string, error) {
+	return c.Command("avd name")
+}
+
+// Kill stops the emulator.
+func (c *Client) Kill() error {
+	_, err := c.Command("kill")
+	return err
+}
+
+// GeoFix sets the GPS location of the device.
+func (c *Client) GeoFix(latitude, longitude float64) error {
+	// the console expects the longitude first
+	_, err := c.Command(fmt.Sprintf("geo fix %s %s", formatFloat(longitude), formatFloat(latitude)))
+	return err
+}
+
+// SendSMS simulates an incoming SMS from the given phone number.
+func (c *Client) SendSMS(sender, text string) error {
+	_, err := c.Command(fmt.Sprintf("sms send %s %s", sender, text))
+	return err
+}
+
+// GSMCall simulates an incoming call from the given phone number.
+func (c *Client) GSMCall(caller string) error {
+	_, err := c.Command("gsm call " + caller)
+	return err
+}
+
+// GSMAccept answers the call from the given phone number.
+func (c *Client) GSMAccept(caller string) error {
+	_, err := c.Command("gsm accept " + caller)
+	return err
+}
+
+// GSMCancel hangs up the call from the given phone number.
+func (c *Client) GSMCancel(caller string) error {
+	_, err := c.Command("gsm cancel " + caller)
+	return err
+}
+
+// FingerTouch simulates touching the fingerprint sensor with the finger of the given id.
+func (c *Client) FingerTouch(fingerprintID int) error {
+	_, err := c.Command(fmt.Sprintf("finger touch %d", fingerprintID))
+	return err
+}
+
+// FingerRemove simulates lifting the finger from the fingerprint sensor.
+func (c *Client) FingerRemove() error {
+	_, err := c.Command("finger remove")
+	return err
+}
+
+// SetPosture sets the posture of a foldable device (closed, half-open, opened, flipped or tent).
+func (c *Client) SetPosture(posture string) error {
+	_, err := c.Command("posture " + posture)
+	return err
+}
+
+// SetBatteryCapacity sets the remaining battery charge of the device in percent.
+func (c *Client) SetBatteryCapacity(percent int) error {
+	_, err := c.Command(fmt.Sprintf("power capacity %d", percent))
+	return err
+}
+
+// SetACCharger connects or disconnects the simulated AC charger.
+func (c *Client) SetACCharger(connected bool) error {
+	state := "off"
+	if connected {
+		state = "on"
+	}
+	_, err := c.Command("power ac " + state)
+	return err
+}
+
+// SetBatteryStatus sets the charging status of the battery (unknown, charging, discharging, not-charging or full).
+func (c *Client) SetBatteryStatus(status string) error {
+	_, err := c.Command("power status " + status)
+	return err
+}
+
+// SetBatteryHealth sets the health of the battery (unknown, good, overheat, dead, overvoltage or failure).
+func (c *Client) SetBatteryHealth(health string) error {
+	_, err := c.Command("power health " + health)
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,96 @@
+package emugrpc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// discoveryDirs returns the directories the running emulators write their discovery files (pid_<pid>.ini) to.
+func discoveryDirs() []string {
+	var dirs []string
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "darwin":
+		dirs = append(dirs, filepath.Join(home, "Library", "Caches", "TemporaryItems", "avd", "running"))
+	case "windows":
+		dirs = append(dirs, filepath.Join(os.Getenv("LOCALAPPDATA"), "Temp", "avd", "running"))
+	default:
+		if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+			dirs = append(dirs, filepath.Join(runtimeDir, "avd", "running"))
+		}
+		dirs = append(dirs, filepath.Join(os.TempDir(), "android-"+os.Getenv("USER"), "avd", "running"))
+	}
+	return dirs
+}
+
+// Endpoint returns the gRPC port and token of the emulator process from its discovery file.
+func Endpoint(pid int) (int, string, error) {
+	var searched []string
+	for _, dir := range discoveryDirs() {
+		pth := filepath.Join(dir, fmt.Sprintf("pid_%d.ini", pid))
+		values, err := readDiscoveryFile(pth)
+		if os.IsNotExist(err) {
+			searched = append(searched, pth)
+			continue
+		} else if err != nil {
+			return 0, "", err
+		}
+
+		portValue, ok := values["grpc.port"]
+		if !ok {
+			return 0, "", fmt.Errorf("the emulator doesn't expose a gRPC endpoint (no grpc.port in %s), start it with the -grpc flag", pth)
+		}
+		port, err := strconv.Atoi(portValue)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid grpc.port (%s) in %s", portValue, pth)
+		}
+		return port, values["grpc.token"], nil
+	}
+	return 0, "", fmt.Errorf("discovery file of the emulator (%d) not found, searched: %s", pid, strings.Join(searched, ", "))
+}
+
+// readDiscoveryFile parses the key=value lines of the discovery file.
+func readDiscoveryFile(pth string) (map[string]string, error) {
+	file, err := os.Open(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if separator := strings.Index(scanner.Text(), "="); separator > 0 {
+			values[strings.TrimSpace(scanner.Text()[:separator])] = strings.TrimSpace(scanner.Text()[separator+1:])
+		}
+	}
+	return values, scanner.Err()
+}
+
+// ProcessID returns the pid of the running emulator with the given console port from the discovery files,
+// which every emulator writes, whether it exposes a gRPC endpoint or not.
+func ProcessID(consolePort int) (int, error) {
+	for _, dir := range discoveryDirs() {
+		paths, err := filepath.Glob(filepath.Join(dir, "pid_*.ini"))
+		if err != nil {
+			return 0, err
+		}
+		for _, pth := range paths {
+			values, err := readDiscoveryFile(pth)
+			if err != nil || values["port.serial"] != strconv.Itoa(consolePort) {
+				continue
+			}
+			pid, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(pth), "pid_"), ".ini"))
+			if err != nil {
+				return 0, fmt.Errorf("invalid discovery file name: %s", pth)
+			}
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("discovery file of the emulator with console port %d not found", consolePort)
+}
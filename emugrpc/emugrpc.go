@@ -0,0 +1,139 @@
+// Package emugrpc implements a client for the gRPC control endpoint of the Android emulator
+// (the EmulatorController and SnapshotService services), which is faster and more faithful than the adb equivalents.
+// The emulator has to be started with the -grpc <port> and -grpc-use-token flags.
+//
+// The messages are encoded by hand with protowire, only the fields used by the client are defined.
+// See: https://android.googlesource.com/platform/external/qemu/+/emu-master-dev/android/android-grpc/python/aemu-grpc/src/aemu/proto
+package emugrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DefaultTimeout is the time a call is allowed to take.
+const DefaultTimeout = 30 * time.Second
+
+const (
+	emulatorControllerService = "/android.emulation.control.EmulatorController/"
+	snapshotService           = "/android.emulation.control.SnapshotService/"
+)
+
+// Client is a connection to the gRPC endpoint of a running emulator.
+type Client struct {
+	conn  *grpc.ClientConn
+	token string
+}
+
+// Dial connects to the gRPC endpoint of the emulator listening on the given port, token is the one of the discovery file.
+func Dial(ctx context.Context, port int, token string) (*Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	// the endpoint listens on the loopback interface without TLS
+	conn, err := grpc.DialContext(ctx, fmt.Sprintf("localhost:%d", port), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to emulator gRPC endpoint: %s", err)
+	}
+	return &Client{conn: conn, token: token}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// invoke calls the method with the encoded request and returns the encoded response.
+func (c *Client) invoke(ctx context.Context, method string, request []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	}
+
+	var response []byte
+	if err := c.conn.Invoke(ctx, method, &request, &response, grpc.ForceCodec(rawCodec{})); err != nil {
+		return nil, fmt.Errorf("%s failed: %s", method, err)
+	}
+	return response, nil
+}
+
+// Screenshot returns the PNG screenshot of the display.
+func (c *Client) Screenshot(ctx context.Context) ([]byte, error) {
+	// the default ImageFormat is PNG of the main display
+	response, err := c.invoke(ctx, emulatorControllerService+"getScreenshot", nil)
+	if err != nil {
+		return nil, err
+	}
+	// Image.image
+	image, err := bytesField(response, 4)
+	if err != nil {
+		return nil, err
+	}
+	if len(image) == 0 {
+		return nil, errors.New("empty screenshot")
+	}
+	return image, nil
+}
+
+// TypeText types the text as keyboard events.
+func (c *Client) TypeText(ctx context.Context, text string) error {
+	// KeyboardEvent.text
+	request := protowire.AppendTag(nil, 5, protowire.BytesType)
+	request = protowire.AppendString(request, text)
+	_, err := c.invoke(ctx, emulatorControllerService+"sendKey", request)
+	return err
+}
+
+// Tap presses and releases the primary mouse button at the given display coordinates.
+func (c *Client) Tap(ctx context.Context, x, y int) error {
+	for _, buttons := range []uint64{1, 0} {
+		// MouseEvent.x, MouseEvent.y, MouseEvent.buttons
+		var request []byte
+		for field, value := range []uint64{uint64(x), uint64(y), buttons} {
+			request = protowire.AppendTag(request, protowire.Number(field+1), protowire.VarintType)
+			request = protowire.AppendVarint(request, value)
+		}
+		if _, err := c.invoke(ctx, emulatorControllerService+"sendMouse", request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot saves the state of the device as the named snapshot.
+func (c *Client) SaveSnapshot(ctx context.Context, name string) error {
+	return c.snapshotCall(ctx, "SaveSnapshot", name)
+}
+
+// LoadSnapshot restores the state of the device from the named snapshot.
+func (c *Client) LoadSnapshot(ctx context.Context, name string) error {
+	return c.snapshotCall(ctx, "LoadSnapshot", name)
+}
+
+// snapshotCall calls a SnapshotService method with the SnapshotPackage of the named snapshot and checks its success.
+func (c *Client) snapshotCall(ctx context.Context, method, name string) error {
+	// SnapshotPackage.snapshot_id
+	request := protowire.AppendTag(nil, 1, protowire.BytesType)
+	request = protowire.AppendString(request, name)
+	response, err := c.invoke(ctx, snapshotService+method, request)
+	if err != nil {
+		return err
+	}
+
+	// SnapshotPackage.success, SnapshotPackage.err
+	success, err := varintField(response, 3)
+	if err != nil {
+		return err
+	}
+	if success == 0 {
+		message, _ := bytesField(response, 4)
+		return fmt.Errorf("%s (%s) failed: %s", method, name, message)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package emugrpc
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawCodec passes the hand encoded messages through as they are.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type: %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type: %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name is the content subtype of the messages, they are protobuf encoded.
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// field returns the last value of the field in the encoded message and its type, a missing field has its default value.
+func field(message []byte, number protowire.Number) (protowire.Type, []byte, error) {
+	var (
+		foundType protowire.Type = -1
+		found     []byte
+	)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return 0, nil, protowire.ParseError(n)
+		}
+		message = message[n:]
+		m := protowire.ConsumeFieldValue(num, typ, message)
+		if m < 0 {
+			return 0, nil, protowire.ParseError(m)
+		}
+		if num == number {
+			foundType, found = typ, message[:m]
+		}
+		message = message[m:]
+	}
+	return foundType, found, nil
+}
+
+// bytesField returns the value of a bytes or string field.
+func bytesField(message []byte, number protowire.Number) ([]byte, error) {
+	typ, value, err := field(message, number)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if typ != protowire.BytesType {
+		return nil, fmt.Errorf("field %d is not a bytes field", number)
+	}
+	b, n := protowire.ConsumeBytes(value)
+	if n < 0 {
+		return nil, protowire.ParseError(n)
+	}
+	return b, nil
+}
+
+// varintField returns the value of an integer, bool or enum field.
+func varintField(message []byte, number protowire.Number) (uint64, error) {
+	typ, value, err := field(message, number)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	if typ != protowire.VarintType {
+		return 0, errors.New("not a varint field")
+	}
+	v, n := protowire.ConsumeVarint(value)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	return v, nil
+}
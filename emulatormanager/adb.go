@@ -0,0 +1,170 @@
+package emulatormanager
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// ADB wraps the Android Debug Bridge binary of the Android SDK.
+type ADB struct {
+	Path string
+
+	ctx context.Context
+}
+
+// NewADB returns the adb client of the SDK installed at androidHome.
+func NewADB(androidHome string) ADB {
+	return ADB{Path: filepath.Join(androidHome, "platform-tools", Executable("adb"))}
+}
+
+// WithContext returns a copy of the client whose commands are killed once ctx is done.
+func (a ADB) WithContext(ctx context.Context) ADB {
+	a.ctx = ctx
+	return a
+}
+
+func (a ADB) context() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+// ErrADBTimeout is returned when an adb command doesn't finish within its timeout.
+var ErrADBTimeout = errors.New("adb command timed out")
+
+const (
+	// adbCommandTimeout is generous, as device commands like bugreport or push can take minutes
+	adbCommandTimeout = time.Duration(5) * time.Minute
+	// ADBServerTimeout limits the commands answered by the adb server itself, a wedged server hangs them
+	ADBServerTimeout = time.Duration(30) * time.Second
+
+	// adbServerProcessPattern matches the command line of the adb server process (adb ... fork-server server ...)
+	adbServerProcessPattern = "adb.*fork-server"
+)
+
+// Run executes an adb command against the device with the given serial and returns its trimmed combined output.
+func (a ADB) Run(serial string, args ...string) (string, error) {
+	return a.RunWithTimeout(serial, adbCommandTimeout, args...)
+}
+
+// RunWithTimeout is like Run, but kills the adb process if it doesn't finish within timeout.
+func (a ADB) RunWithTimeout(serial string, timeout time.Duration, args ...string) (string, error) {
+	return a.Exec(timeout, append([]string{"-s", serial}, args...)...)
+}
+
+// Exec runs adb with the given arguments and kills the process if it doesn't finish within timeout or the context of the client is done.
+func (a ADB) Exec(timeout time.Duration, args ...string) (string, error) {
+	var output syncBuffer
+	cmd := command.New(a.Path, args...).SetStdout(&output).SetStderr(&output)
+	log.Debugf("[%s %s] $ %s", logTimestamp(), adbTag, cmd.PrintableCommandArgs())
+
+	if err := cmd.GetCmd().Start(); err != nil {
+		return "", fmt.Errorf("%s failed: %s", cmd.PrintableCommandArgs(), err)
+	}
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- cmd.GetCmd().Wait()
+	}()
+
+	timeoutTimer := time.NewTimer(timeout)
+	defer timeoutTimer.Stop()
+
+	select {
+	case err := <-doneCh:
+		out := strings.TrimSpace(output.String())
+		if err != nil {
+			return out, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+		}
+		return out, nil
+	case <-timeoutTimer.C:
+		if err := cmd.GetCmd().Process.Kill(); err != nil {
+			log.Warnf("Failed to kill adb process: %s", err)
+		}
+		<-doneCh
+		return strings.TrimSpace(output.String()), fmt.Errorf("%s: %w after %s", cmd.PrintableCommandArgs(), ErrADBTimeout, timeout)
+	case <-a.context().Done():
+		if err := cmd.GetCmd().Process.Kill(); err != nil {
+			log.Warnf("Failed to kill adb process: %s", err)
+		}
+		<-doneCh
+		return strings.TrimSpace(output.String()), fmt.Errorf("%s: %w", cmd.PrintableCommandArgs(), a.context().Err())
+	}
+}
+
+// Shell runs a shell command on the device with the given serial.
+func (a ADB) Shell(serial string, args ...string) (string, error) {
+	return a.Run(serial, append([]string{"shell"}, args...)...)
+}
+
+// Devices returns the state of the running emulators mapped by their serial.
+// A hanging adb server is killed and restarted, and the device list is queried again.
+func (a ADB) Devices() (map[string]string, error) {
+	out, err := a.Exec(ADBServerTimeout, "devices")
+	if errors.Is(err, ErrADBTimeout) {
+		log.Warnf("adb server is not responding, restarting it: %s", err)
+		if restartErr := a.RestartHungServer(); restartErr != nil {
+			return map[string]string{}, fmt.Errorf("failed to restart hung adb server: %s", restartErr)
+		}
+		out, err = a.Exec(ADBServerTimeout, "devices")
+	}
+	if err != nil {
+		log.Printf("%s", err)
+		return map[string]string{}, fmt.Errorf("command failed, error: %s", err)
+	}
+
+	log.Debugf("%s", out)
+
+	// List of devices attached
+	// emulator-5554	device
+	deviceListItemPattern := `^(?P<emulator>emulator-\d*)[\s+](?P<state>.*)`
+	deviceListItemRegexp := regexp.MustCompile(deviceListItemPattern)
+
+	deviceStateMap := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		matches := deviceListItemRegexp.FindStringSubmatch(line)
+		if len(matches) == 3 {
+			serial := matches[1]
+			state := matches[2]
+
+			deviceStateMap[serial] = state
+		}
+
+	}
+	if scanner.Err() != nil {
+		return map[string]string{}, fmt.Errorf("scanner failed, error: %s", err)
+	}
+
+	return deviceStateMap, nil
+}
+
+// RestartServer restarts the adb server, dropping the devices which are not available anymore.
+func (a ADB) RestartServer() error {
+	for _, args := range [][]string{{"kill-server"}, {"start-server"}} {
+		if _, err := a.Exec(ADBServerTimeout, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestartHungServer kills the adb server process (adb kill-server can't reach a wedged server) and starts a new one.
+func (a ADB) RestartHungServer() error {
+	if err := KillProcesses(adbServerProcessPattern); err != nil {
+		return fmt.Errorf("failed to kill adb server process: %s", err)
+	}
+	_, err := a.Exec(ADBServerTimeout, "start-server")
+	return err
+}
@@ -0,0 +1,65 @@
+package emulatormanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// AVDHome returns the directory where the AVDs are stored, following the emulator's lookup order.
+func AVDHome() (string, error) {
+	if dir := os.Getenv("ANDROID_AVD_HOME"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("ANDROID_EMULATOR_HOME"); dir != "" {
+		return filepath.Join(dir, "avd"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".android", "avd"), nil
+}
+
+// ListAVDs returns the names of the AVDs the emulator can start, as reported by emulator -list-avds.
+func ListAVDs(emulatorPath string) ([]string, error) {
+	cmd := command.New(emulatorPath, "-list-avds")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		// the list can be preceded by log lines of the emulator, like: INFO    | Storing crashdata in: ...
+		if line == "" || strings.Contains(line, "|") || strings.Contains(line, " ") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, nil
+}
+
+// CheckAVDExists fails with the list of the available AVDs if the emulator can't find the named AVD,
+// otherwise the emulator prints an error and the device never shows up in adb.
+func CheckAVDExists(emulatorPath, name string) error {
+	names, err := ListAVDs(emulatorPath)
+	if err != nil {
+		return err
+	}
+	if containsString(names, name) {
+		return nil
+	}
+	home, err := AVDHome()
+	if err != nil {
+		home = "the AVD home"
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("AVD (%s) not found in %s, there are no AVDs available", name, home)
+	}
+	return fmt.Errorf("AVD (%s) not found in %s, available AVDs: %s", name, home, strings.Join(names, ", "))
+}
@@ -0,0 +1,77 @@
+package emulatormanager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// emulatorBinaryCandidates returns the possible locations of the emulator binary within an SDK root:
+// the emulator package and the emulator of the legacy SDK tools package.
+func emulatorBinaryCandidates(sdkRoot string) []string {
+	return []string{
+		filepath.Join(sdkRoot, "emulator", Executable("emulator")),
+		filepath.Join(sdkRoot, "tools", Executable("emulator")),
+	}
+}
+
+// Executable returns the file name of the SDK binary on the host OS (with the .exe extension on Windows).
+func Executable(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// checkExecutable returns why the file at pth can't be run as the emulator, or nil.
+func checkExecutable(pth string) error {
+	info, err := os.Stat(pth)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("does not exist")
+	} else if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file")
+	}
+	// Windows has no executable permission bits, the .exe extension makes a file executable
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("not executable")
+	}
+	return nil
+}
+
+// FindEmulator returns the path of the emulator binary: the emulator package of the first SDK root which has one,
+// then the legacy SDK tools and finally the emulator on the PATH. The error lists every searched path.
+func FindEmulator(sdkRoots ...string) (string, error) {
+	var searched []string
+	seen := map[string]bool{}
+	for _, sdkRoot := range sdkRoots {
+		if sdkRoot == "" {
+			continue
+		}
+		for _, pth := range emulatorBinaryCandidates(sdkRoot) {
+			if seen[pth] {
+				continue
+			}
+			seen[pth] = true
+
+			err := checkExecutable(pth)
+			if err == nil {
+				return pth, nil
+			}
+			searched = append(searched, fmt.Sprintf("- %s: %s", pth, err))
+		}
+	}
+
+	pth, err := exec.LookPath("emulator")
+	if err == nil {
+		return pth, nil
+	}
+	searched = append(searched, fmt.Sprintf("- emulator on the PATH: %s", err))
+
+	return "", fmt.Errorf("emulator binary not found, searched:\n%s", strings.Join(searched, "\n"))
+}
@@ -0,0 +1,112 @@
+package emulatormanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const bootCompleteCheckInterval = time.Duration(3) * time.Second
+
+// BootCompletion configures how long and for which readiness conditions the boot completion is waited for.
+type BootCompletion struct {
+	Timeout    time.Duration
+	FormFactor FormFactor
+	PlayStore  bool
+	// StuckTimeout is the time the boot may go without progress before it is considered stuck, 0 disables the detection
+	StuckTimeout time.Duration
+}
+
+// ErrBootStuck is returned when the boot doesn't progress, for example because the boot animation hangs.
+var ErrBootStuck = errors.New("boot is stuck")
+
+// bootProgress returns the number of boot progress events (boot_progress_start, boot_progress_pms_ready, ...) logged so far.
+func bootProgress(adbClient ADB, serial string) (int, error) {
+	out, err := adbClient.Shell(serial, "logcat", "-b", "events", "-d")
+	if err != nil {
+		return 0, err
+	}
+	return strings.Count(out, "boot_progress_"), nil
+}
+
+// pendingBootChecks returns the readiness conditions the device doesn't satisfy yet.
+func pendingBootChecks(adbClient ADB, serial string, boot BootCompletion) []string {
+	var pending []string
+
+	if out, err := adbClient.Shell(serial, "getprop", "sys.boot_completed"); err != nil || out != "1" {
+		pending = append(pending, "sys.boot_completed")
+	}
+	// the boot animation service never starts with -no-boot-anim, so only a running animation is a blocker
+	if out, err := adbClient.Shell(serial, "getprop", "init.svc.bootanim"); err != nil || out == "running" {
+		pending = append(pending, "init.svc.bootanim")
+	}
+	if out, err := adbClient.Shell(serial, "pm", "path", "android"); err != nil || !strings.HasPrefix(out, "package:") {
+		pending = append(pending, "package manager")
+	}
+	if feature := boot.FormFactor.systemFeature(); feature != "" {
+		if featurePending(adbClient, serial, feature) {
+			pending = append(pending, feature)
+		} else if homeScreenPending(adbClient, serial) {
+			pending = append(pending, fmt.Sprintf("%s home screen", boot.FormFactor))
+		}
+	}
+	if boot.PlayStore && playServicesPending(adbClient, serial) {
+		pending = append(pending, "Google Play services")
+	}
+
+	return pending
+}
+
+// WaitForBootComplete polls the device until the boot is completed and the package manager is ready,
+// or until ctx is done.
+func WaitForBootComplete(ctx context.Context, adbClient ADB, serial string, boot BootCompletion) error {
+	adbClient = adbClient.WithContext(ctx)
+	log.Printf("Waiting for device (%s) to complete the boot", serial)
+
+	timeout := boot.Timeout + boot.FormFactor.extraBootTime()
+	timeoutTimer := time.NewTimer(timeout)
+	defer timeoutTimer.Stop()
+
+	checkTicker := time.NewTicker(bootCompleteCheckInterval)
+	defer checkTicker.Stop()
+
+	pending := []string{"sys.boot_completed", "init.svc.bootanim", "package manager"}
+	progress, lastProgress := 0, time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("boot completion phase of device (%s) aborted: %w", serial, ctx.Err())
+		case <-timeoutTimer.C:
+			return fmt.Errorf("boot completion phase timed out: device (%s) did not complete the boot within %s, pending: %s", serial, timeout, strings.Join(pending, ", "))
+		case <-checkTicker.C:
+			pending = pendingBootChecks(adbClient, serial, boot)
+			if len(pending) == 0 {
+				log.Printf("- Device (%s) boot completed", serial)
+				return nil
+			}
+			log.Debugf("Device (%s) boot pending: %s", serial, strings.Join(pending, ", "))
+
+			if boot.StuckTimeout == 0 || !containsString(pending, "sys.boot_completed") && !containsString(pending, "init.svc.bootanim") {
+				continue
+			}
+			if current, err := bootProgress(adbClient, serial); err == nil && current > progress {
+				progress, lastProgress = current, time.Now()
+			} else if time.Since(lastProgress) > boot.StuckTimeout {
+				return fmt.Errorf("%w: device (%s) made no boot progress in %s, pending: %s", ErrBootStuck, serial, boot.StuckTimeout, strings.Join(pending, ", "))
+			}
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
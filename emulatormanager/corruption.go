@@ -0,0 +1,59 @@
+package emulatormanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// errCorruptAVD is returned when the emulator log shows that the persisted state of the AVD is broken,
+// restarting the emulator with the same state would fail the same way.
+var errCorruptAVD = errors.New("emulator reported a corrupt AVD state")
+
+// corruptionIndicators are emulator log lines signaling a broken disk image or snapshot.
+var corruptionIndicators = []string{
+	"Image is corrupt",
+	"image is corrupt",
+	"Corrupt image",
+	"qcow2: Marking image as corrupt",
+	"Failed to load snapshot",
+	"Snapshot load failed",
+	"userdata-qemu.img: No such file",
+	"Could not open userdata",
+}
+
+// avdStateFiles are the (glob patterns of the) files in the AVD directory holding its persisted state.
+// The emulator recreates them from the system image on the next start; config.ini and the hardware profile are kept.
+var avdStateFiles = []string{
+	"userdata-qemu.img*",
+	"cache.img*",
+	"encryptionkey.img*",
+	"snapshots",
+	"*.lock",
+}
+
+// resetAVDState removes the persisted state of the AVD, so the next boot attempt starts from a clean slate.
+func resetAVDState(avdName string) error {
+	dir, err := AVDHome()
+	if err != nil {
+		return err
+	}
+	avdDir := filepath.Join(dir, avdName+".avd")
+
+	for _, pattern := range avdStateFiles {
+		matches, err := filepath.Glob(filepath.Join(avdDir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			log.Printf("Removing %s", match)
+			if err := os.RemoveAll(match); err != nil {
+				return fmt.Errorf("failed to remove %s: %s", match, err)
+			}
+		}
+	}
+	return nil
+}
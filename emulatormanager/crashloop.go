@@ -0,0 +1,59 @@
+package emulatormanager
+
+import (
+	"errors"
+	"regexp"
+)
+
+// errCrashLoop is returned when consecutive boot attempts fail with the same crashSignature.
+var errCrashLoop = errors.New("configuration error, retrying won't help")
+
+// crashSignature is an emulator log signature of a failure caused by the host, the AVD or the system image.
+// A single occurrence might be transient, but once it repeats on the next attempt, restarting the emulator won't fix it.
+type crashSignature struct {
+	name        string
+	pattern     *regexp.Regexp
+	remediation string
+}
+
+var crashSignatures = []crashSignature{
+	{
+		name:        "KVM is not usable",
+		pattern:     regexp.MustCompile(`(?i)(/dev/kvm[^\n]*(permission denied|not found|no such file)|KVM is (not installed|required)|doesn't have permissions to use KVM|Could not initialize KVM)`),
+		remediation: "Make sure KVM is enabled and the current user can read and write /dev/kvm, or use the software emulation fallback.",
+	},
+	{
+		name:        "the hypervisor is not usable",
+		pattern:     regexp.MustCompile(`(?i)(HVF error|hv_vm_create|HAXM is not installed|failed to initialize HAX|WHPX[^\n]*(failed|error))`),
+		remediation: "Make sure the hypervisor of the host (Hypervisor.framework, WHPX or AEHD) is available, or use the software emulation fallback.",
+	},
+	{
+		name:        "the host GPU driver can't be loaded",
+		pattern:     regexp.MustCompile(`(?i)(libGL error|Failed to load (libGL|libEGL|libvulkan)|Could not load (libGL|libEGL)|GLX is not supported)`),
+		remediation: "Install the GPU drivers of the host, or select the swiftshader_indirect GPU mode.",
+	},
+	{
+		name:        "the system image is broken",
+		pattern:     regexp.MustCompile(`(?i)(Cannot find AVD system path|Broken AVD system path|kernel file not found|Could not open '[^']*(system|vendor|ramdisk|kernel-ranchu)[^']*'|(system|vendor|ramdisk)\.img: No such file)`),
+		remediation: "Reinstall the system image package with sdkmanager, the installed one is missing files or is corrupt.",
+	},
+}
+
+// findCrashSignature returns the first crash signature found in the emulator output, or nil.
+func findCrashSignature(output string) *crashSignature {
+	for i := range crashSignatures {
+		if crashSignatures[i].pattern.MatchString(output) {
+			return &crashSignatures[i]
+		}
+	}
+	return nil
+}
+
+// attemptCrashSignature returns the crash signature of the failed attempt, or nil.
+func attemptCrashSignature(err error) *crashSignature {
+	var exitErr exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.signature
+	}
+	return nil
+}
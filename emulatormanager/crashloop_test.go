@@ -0,0 +1,57 @@
+package emulatormanager
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFindCrashSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "KVM permission", output: "ERROR   | /dev/kvm is not found or permission denied", want: "KVM is not usable"},
+		{name: "KVM permissions of the user", output: "This user doesn't have permissions to use KVM (/dev/kvm).", want: "KVM is not usable"},
+		{name: "HVF", output: "HVF error: HV_ERROR", want: "the hypervisor is not usable"},
+		{name: "HAXM", output: "HAXM is not installed on this machine", want: "the hypervisor is not usable"},
+		{name: "GPU driver", output: "libGL error: failed to load driver: swrast", want: "the host GPU driver can't be loaded"},
+		{name: "missing system image", output: "PANIC: Cannot find AVD system path. Please define ANDROID_SDK_ROOT", want: "the system image is broken"},
+		{name: "missing image file", output: "qemu: Could not open '/sdk/system-images/android-30/google_apis/x86/system.img'", want: "the system image is broken"},
+		{name: "transient failure", output: "emulator: ERROR: Running multiple emulators with the same AVD"},
+		{name: "empty output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if signature := findCrashSignature(tt.output); signature != nil {
+				got = signature.name
+			}
+			if got != tt.want {
+				t.Errorf("findCrashSignature(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttemptCrashSignature(t *testing.T) {
+	signature := &crashSignatures[0]
+	tests := []struct {
+		name string
+		err  error
+		want *crashSignature
+	}{
+		{name: "exit with signature", err: retryableError{exitError{signature: signature}}, want: signature},
+		{name: "wrapped exit with signature", err: fmt.Errorf("attempt 2: %w", exitError{signature: signature}), want: signature},
+		{name: "exit without signature", err: exitError{}},
+		{name: "other error", err: errors.New("device not found")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attemptCrashSignature(tt.err); got != tt.want {
+				t.Errorf("attemptCrashSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
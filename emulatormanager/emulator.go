@@ -0,0 +1,606 @@
+// Package emulatormanager starts Android emulators and waits for their devices to become ready.
+//
+// Boot runs a single emulator instance: it retries failed attempts, falls back to other GPU modes
+// and recovers corrupt AVD state, then returns the details of the online Device.
+package emulatormanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+)
+
+var (
+	faultIndicators = []string{" BUG: ", "Kernel panic"}
+	// errEmulatorFault is returned when the emulator log contains one of the faultIndicators
+	errEmulatorFault = errors.New("emulator log contains fault")
+)
+
+const (
+	// offlineReconnectPolls is the number of checks a device can stay offline before adb reconnects it
+	offlineReconnectPolls = 6
+	// unauthorizedPolls is the number of checks a device can stay unauthorized before the boot attempt fails,
+	// the state is normal for a short while until adbd loads the keys
+	unauthorizedPolls = 6
+	// detectionProgressInterval is how often the device detection reports that it is still waiting,
+	// the individual checks are only logged when the device state changes
+	detectionProgressInterval = time.Duration(30) * time.Second
+
+	BootRetryInitialDelay = time.Duration(5) * time.Second
+	BootRetryMaxDelay     = time.Duration(1) * time.Minute
+)
+
+// queryNewDevice returns the serial and the adb state of the started device, both are empty until it shows up in adb.
+// If expectedSerial is empty, the started device is the one missing from runningDevices.
+func queryNewDevice(adbClient ADB, runningDevices map[string]string, expectedSerial string) (string, string, error) {
+	currentRunningDevices, err := adbClient.Devices()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to check running devices: %s", err)
+	}
+
+	if expectedSerial != "" {
+		return expectedSerial, currentRunningDevices[expectedSerial], nil
+	}
+	for serial, state := range currentRunningDevices {
+		if _, found := runningDevices[serial]; !found {
+			return serial, state, nil
+		}
+	}
+	return "", "", nil
+}
+
+// BootOptions holds everything needed to boot a single emulator instance.
+type BootOptions struct {
+	EmulatorPath string
+	AVDName      string
+	// Port is the console port of the emulator, the emulator picks a free port if 0
+	Port int
+	// DefaultFlags are the built-in flags, the -gpu flag value is set according to GPUMode
+	DefaultFlags   []StartFlag
+	GPUMode        string
+	DisabledFlags  []string
+	CustomFlags    []string
+	RetryPolicy    RetryPolicy
+	RunningDevices map[string]string
+	// DetectionTimeout is the time a boot attempt has for the device to appear online in adb
+	DetectionTimeout time.Duration
+	Polling          DevicePolling
+	// LogPath is the file the raw emulator output is persisted to, the output is not persisted if empty
+	LogPath string
+	// LogVerbosity controls how the emulator output of a failed attempt is printed, LogNormal if empty
+	LogVerbosity LogVerbosity
+	// OnFailure is called before a failing emulator is killed, with the serial of the device (if any)
+	// and whether the device came online
+	OnFailure func(serial string, online bool)
+	// OnProcessStarted is called with the PID of the emulator process of each boot attempt
+	OnProcessStarted func(pid int)
+	// Boot configures the boot completion check of each attempt, the attempt succeeds once the device is online if nil
+	Boot *BootCompletion
+	// OnEvent is called with the lifecycle events of the boot, like the device detection or a matched fault
+	OnEvent func(Event)
+	// OnBootCompleted is called with the serial of the device once a boot attempt succeeded, to configure the state
+	// a restarted emulator process doesn't keep. Its error fails the attempt and the emulator is shut down.
+	OnBootCompleted func(serial string) error
+}
+
+// Device describes an emulator started by Boot.
+type Device struct {
+	Serial      string
+	ConsolePort int
+	AVDName     string
+	// Retries is the number of failed boot attempts before the successful one
+	Retries int
+	// Args are the emulator command arguments of the successful attempt
+	Args []string
+	// PID is the process ID of the emulator process of the successful attempt
+	PID int
+	// ProcessStarted and DeviceDetected are the start of the successful attempt and the time the device came online
+	ProcessStarted time.Time
+	DeviceDetected time.Time
+}
+
+// Args returns the emulator command arguments of the first boot attempt.
+func (opts BootOptions) Args() []string {
+	return opts.args(opts.GPUMode)
+}
+
+func (opts BootOptions) args(gpuMode string) []string {
+	customFlags := opts.CustomFlags
+	if opts.Port > 0 {
+		customFlags = append([]string{"-port", strconv.Itoa(opts.Port)}, customFlags...)
+	}
+	return StartArgs(opts.AVDName, WithFlagValues(opts.DefaultFlags, "-gpu", gpuMode), opts.DisabledFlags, customFlags)
+}
+
+func (opts BootOptions) onFailure(serial string, online bool) {
+	if opts.OnFailure != nil {
+		opts.OnFailure(serial, online)
+	}
+}
+
+// Boot starts the emulator according to opts, retrying on failures, and returns the details of the online device.
+// Once ctx is done, the adb commands are killed and the emulator of the ongoing attempt is shut down.
+func Boot(ctx context.Context, adbClient ADB, opts BootOptions) (Device, error) {
+	adbClient = adbClient.WithContext(ctx)
+	expectedSerial := ""
+	if opts.Port > 0 {
+		expectedSerial = fmt.Sprintf("emulator-%d", opts.Port)
+	}
+
+	var logWriter io.Writer
+	if opts.LogPath != "" {
+		logFile, err := newRotatingFile(opts.LogPath, emulatorLogMaxSize, emulatorLogMaxFiles)
+		if err != nil {
+			log.Warnf("Failed to create emulator log file: %s", err)
+		} else {
+			logWriter = logFile
+		}
+	}
+
+	logVerbosity := opts.LogVerbosity
+	if logVerbosity == "" {
+		logVerbosity = LogNormal
+	}
+
+	device := Device{AVDName: opts.AVDName}
+	gpuModes := newGPUModeChain(opts.GPUMode)
+	// a -gpu flag of the user overrides the GPU mode, falling back would retry the very same configuration
+	gpuOverridden := flagNames(opts.CustomFlags)["-gpu"]
+	onRetry := func(attempt int, err error) {
+		opts.emit(EventRestartTriggered, attempt, "", err.Error())
+	}
+	serial, err := bootWithRetry(ctx, opts.RetryPolicy, onRetry, func(attempt int) (string, error) {
+		if logWriter != nil {
+			if _, err := fmt.Fprintf(logWriter, "\n=== Boot attempt %d ===\n", attempt); err != nil {
+				log.Warnf("Failed to write emulator log file: %s", err)
+			}
+		}
+
+		gpuMode := gpuModes.mode()
+		args := opts.args(gpuMode)
+		device.Retries, device.Args = attempt-1, args
+		device.ProcessStarted = time.Now()
+
+		instance, err := startEmulator(ctx, bootAttempt{
+			emulatorPath:   opts.EmulatorPath,
+			args:           args,
+			adbClient:      adbClient,
+			runningDevices: opts.RunningDevices,
+			expectedSerial: expectedSerial,
+			logWriter:      logWriter,
+			timeout:        opts.DetectionTimeout,
+			polling:        opts.Polling,
+			onFailure: func(serial string) {
+				opts.onFailure(serial, false)
+			},
+			onStarted: func(pid int) {
+				opts.emit(EventProcessStarted, attempt, expectedSerial, fmt.Sprintf("pid %d", pid))
+				if opts.OnProcessStarted != nil {
+					opts.OnProcessStarted(pid)
+				}
+			},
+			logVerbosity: logVerbosity,
+			logPath:      opts.LogPath,
+		})
+		if isFault(err) {
+			opts.emit(EventFaultMatched, attempt, expectedSerial, err.Error())
+		}
+		if errors.Is(err, errRendererFault) {
+			return "", gpuFallback(gpuModes, gpuOverridden, err)
+		}
+		if errors.Is(err, errCorruptAVD) {
+			log.Warnf("Resetting the state of the AVD (%s) before the next attempt", opts.AVDName)
+			if resetErr := resetAVDState(opts.AVDName); resetErr != nil {
+				log.Warnf("Failed to reset AVD state: %s", resetErr)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+
+		device.DeviceDetected = time.Now()
+		device.PID = instance.process.Pid
+		opts.emit(EventDeviceDetected, attempt, instance.serial, "")
+		completed := func() (string, error) {
+			if opts.OnBootCompleted == nil {
+				return instance.serial, nil
+			}
+			if err := opts.OnBootCompleted(instance.serial); err != nil {
+				log.Warnf("Shutting down the emulator: %s", err)
+				if shutdownErr := ShutdownEmulator(adbClient, instance.serial, instance.process, instance.exited); shutdownErr != nil {
+					log.Warnf("Couldn't finish emulator process: %v", shutdownErr)
+				}
+				return "", err
+			}
+			return instance.serial, nil
+		}
+		if opts.Boot == nil {
+			return completed()
+		}
+		if err := WaitForBootComplete(ctx, adbClient, instance.serial, *opts.Boot); err != nil {
+			if ctx.Err() != nil {
+				log.Warnf("Shutting down the emulator: %s", err)
+				if shutdownErr := ShutdownEmulator(adbClient.WithContext(context.Background()), instance.serial, instance.process, instance.exited); shutdownErr != nil {
+					log.Warnf("Couldn't finish emulator process: %v", shutdownErr)
+				}
+				return "", err
+			}
+			opts.onFailure(instance.serial, true)
+			// the device can come online with a broken renderer, then the boot never completes
+			if containsAny(instance.output.String(), rendererFaultIndicators) {
+				err = retryableError{fmt.Errorf("%w: %s", errRendererFault, err)}
+				opts.emit(EventFaultMatched, attempt, instance.serial, err.Error())
+				if shutdownErr := ShutdownEmulator(adbClient, instance.serial, instance.process, instance.exited); shutdownErr != nil {
+					log.Warnf("Couldn't finish emulator process: %v", shutdownErr)
+				}
+				return "", gpuFallback(gpuModes, gpuOverridden, err)
+			}
+			if isFault(err) {
+				opts.emit(EventFaultMatched, attempt, instance.serial, err.Error())
+			}
+			if !errors.Is(err, ErrBootStuck) {
+				// the step fails or retries with a new emulator, the online one would be left behind
+				log.Warnf("Shutting down the emulator: %s", err)
+				if shutdownErr := ShutdownEmulator(adbClient, instance.serial, instance.process, instance.exited); shutdownErr != nil {
+					log.Warnf("Couldn't finish emulator process: %v", shutdownErr)
+				}
+				return "", err
+			}
+			// a wedged boot doesn't recover by waiting, restarting the emulator is quicker than burning the timeout
+			log.Warnf("Restarting the emulator: %s", err)
+			if shutdownErr := ShutdownEmulator(adbClient, instance.serial, instance.process, instance.exited); shutdownErr != nil {
+				log.Warnf("Couldn't finish emulator process: %v", shutdownErr)
+			}
+			return "", retryableError{err}
+		}
+		opts.emit(EventBootCompleted, attempt, instance.serial, "")
+		return completed()
+	})
+	if err != nil {
+		opts.emit(EventBootFailed, device.Retries+1, "", err.Error())
+		return Device{}, err
+	}
+
+	device.Serial = serial
+	if port, err := emuconsole.PortFromSerial(serial); err == nil {
+		device.ConsolePort = port
+	}
+	return device, nil
+}
+
+// DevicePolling configures how a boot attempt checks whether the device is online.
+type DevicePolling struct {
+	Interval time.Duration
+	// ADBRestartThreshold restarts the adb server after every that many checks without the device, 0 disables it
+	ADBRestartThreshold int
+}
+
+func (p DevicePolling) restartRequired(polls int) bool {
+	return p.ADBRestartThreshold > 0 && polls%p.ADBRestartThreshold == 0
+}
+
+// bootAttempt holds the parameters of a single emulator start.
+type bootAttempt struct {
+	emulatorPath   string
+	args           []string
+	adbClient      ADB
+	runningDevices map[string]string
+	expectedSerial string
+	logWriter      io.Writer
+	// timeout is the time the device has to appear online in adb
+	timeout time.Duration
+	polling DevicePolling
+	// onFailure is called before a failing emulator is killed, with the serial of the not yet online device (if any)
+	onFailure func(serial string)
+	// onStarted is called with the PID of the started emulator process
+	onStarted func(pid int)
+	// logVerbosity and logPath control how the emulator output of a failed attempt is printed
+	logVerbosity LogVerbosity
+	logPath      string
+}
+
+// printEmulatorLog prints the emulator output of the failed attempt filtered according to the log verbosity.
+func printEmulatorLog(output string, attempt bootAttempt) {
+	log.Printf("Emulator log: %s", filterEmulatorLog(output, attempt.logVerbosity))
+	if attempt.logVerbosity != LogDebug && attempt.logPath != "" {
+		log.Printf("The complete emulator log is saved to: %s", attempt.logPath)
+	}
+}
+
+// pendingDeviceSerial returns the serial of the started device in any state (for example offline).
+func pendingDeviceSerial(attempt bootAttempt) string {
+	if attempt.expectedSerial != "" {
+		return attempt.expectedSerial
+	}
+	currentRunningDevices, err := attempt.adbClient.Devices()
+	if err != nil {
+		return ""
+	}
+	for serial := range currentRunningDevices {
+		if _, found := attempt.runningDevices[serial]; !found {
+			return serial
+		}
+	}
+	return ""
+}
+
+// detectionStatus describes the adb state of the not yet online device.
+func detectionStatus(serial, state string) string {
+	if state == "" {
+		return "the device is not listed in adb yet"
+	}
+	return fmt.Sprintf("device (%s) is %s", serial, state)
+}
+
+// exitError describes an unexpected exit of the emulator process with the last lines of its output,
+// so the restart log and the boot error show why the emulator died.
+type exitError struct {
+	status string
+	tail   []string
+	// signature is the crash signature found in the output, if any
+	signature *crashSignature
+}
+
+func (e exitError) Error() string {
+	status := e.status
+	if e.signature != nil {
+		status += ", " + e.signature.name
+	}
+	if len(e.tail) == 0 {
+		return fmt.Sprintf("emulator exited early (%s) without output", status)
+	}
+	return fmt.Sprintf("emulator exited early (%s), last %d output line(s):\n    %s", status, len(e.tail), strings.Join(e.tail, "\n    "))
+}
+
+// exitStatus returns the exit code of the emulator process, or the signal which killed it.
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit code 0"
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err.Error()
+	}
+	if code := exitErr.ExitCode(); code >= 0 {
+		return fmt.Sprintf("exit code %d", code)
+	}
+	// the exit code is -1 if the process was terminated by a signal
+	return exitErr.ProcessState.String()
+}
+
+// emulatorInstance is a started emulator process whose device is online in adb.
+type emulatorInstance struct {
+	serial  string
+	process *os.Process
+	exited  ExitWaiter
+	// output keeps collecting the emulator output after the device is online
+	output *syncBuffer
+}
+
+// startEmulator starts the emulator process and waits until its device is online in adb.
+// Once ctx is done, the emulator is shut down and the context's error is returned.
+func startEmulator(ctx context.Context, attempt bootAttempt) (emulatorInstance, error) {
+	var output syncBuffer
+	var outWriter io.Writer = &output
+	if attempt.logWriter != nil {
+		outWriter = io.MultiWriter(&output, attempt.logWriter)
+	}
+	// every line is tagged with its source and timestamped, so the interleaving with the step's own events can be analyzed
+	stdoutWriter := newTaggedLineWriter(emulatorStdoutTag, outWriter)
+	stderrWriter := newTaggedLineWriter(emulatorStderrTag, outWriter)
+	deviceStartCmd := command.New(attempt.emulatorPath, attempt.args...).SetStdout(stdoutWriter).SetStderr(stderrWriter)
+
+	log.Infof("Starting device")
+	log.Donef("$ %s", deviceStartCmd.PrintableCommandArgs())
+
+	// The emulator command won't exit after the boot completes, so we start the command and not wait for its result.
+	// Instead, we have a loop with 4 channels:
+	// 1. The context, which aborts the boot attempt
+	// 2. One that waits for the emulator process to exit
+	// 3. A boot timeout timer
+	// 4. A ticker that periodically checks if the device has become online
+	// the emulator gets its own process group, so it can be killed together with its qemu child processes
+	StartInProcessGroup(deviceStartCmd.GetCmd())
+	if err := deviceStartCmd.GetCmd().Start(); err != nil {
+		return emulatorInstance{}, fmt.Errorf("failed to run device start command: %v", err)
+	}
+	if attempt.onStarted != nil {
+		attempt.onStarted(deviceStartCmd.GetCmd().Process.Pid)
+	}
+
+	emulatorWaitCh := make(chan error, 1)
+	emulatorExitedCh := make(chan struct{})
+	go func() {
+		err := deviceStartCmd.GetCmd().Wait()
+		for _, w := range []*taggedLineWriter{stdoutWriter, stderrWriter} {
+			if flushErr := w.Flush(); flushErr != nil {
+				log.Warnf("Failed to write emulator output: %s", flushErr)
+			}
+		}
+		emulatorWaitCh <- err
+		close(emulatorExitedCh)
+	}()
+
+	shutdown := func() error {
+		// the shutdown has to finish even if the boot is aborted
+		shutdownAttempt := attempt
+		shutdownAttempt.adbClient = attempt.adbClient.WithContext(context.Background())
+		serial := pendingDeviceSerial(shutdownAttempt)
+		return ShutdownEmulator(shutdownAttempt.adbClient, serial, deviceStartCmd.GetCmd().Process, channelExitWaiter(emulatorExitedCh))
+	}
+	killEmulator := func() error {
+		if attempt.onFailure != nil {
+			attempt.onFailure(pendingDeviceSerial(attempt))
+		}
+		return shutdown()
+	}
+
+	// A failed attempt must not leave anything behind for the next one: the emulator process is shut down
+	// if a failure path didn't do it, and its qemu children are killed even if it exited on its own.
+	// The wait goroutine (and the output copying goroutines of the command) finish once the process is gone.
+	online := false
+	defer func() {
+		if online {
+			return
+		}
+		select {
+		case <-emulatorExitedCh:
+			killLeftoverChildren(deviceStartCmd.GetCmd().Process)
+		default:
+			if err := shutdown(); err != nil {
+				log.Warnf("Couldn't finish emulator process of the failed attempt: %v", err)
+			}
+		}
+	}()
+
+	timeoutTimer := time.NewTimer(attempt.timeout)
+	defer timeoutTimer.Stop()
+
+	deviceCheckTicker := time.NewTicker(attempt.polling.Interval)
+	defer deviceCheckTicker.Stop()
+	polls := 0
+	lastState, stateSince := "", 0
+	detectionStart := time.Now()
+	lastProgressReport := detectionStart
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Warnf("Device detection phase aborted, shutting down the emulator")
+			if err := shutdown(); err != nil {
+				log.Warnf("Couldn't finish emulator process: %v", err)
+			}
+			return emulatorInstance{}, fmt.Errorf("device detection phase aborted: %w", ctx.Err())
+		case err := <-emulatorWaitCh:
+			log.Warnf("Emulator process exited early")
+			if err != nil {
+				log.Errorf("Emulator exit reason: %s", exitStatus(err))
+			} else {
+				log.Warnf("A possible cause can be the emulator process having received a KILL signal.")
+			}
+			printEmulatorLog(output.String(), attempt)
+			if containsAny(output.String(), corruptionIndicators) {
+				return emulatorInstance{}, retryableError{errCorruptAVD}
+			}
+			if err := findFatalFault(output.String()); err != nil {
+				return emulatorInstance{}, err
+			}
+			if containsAny(output.String(), rendererFaultIndicators) {
+				return emulatorInstance{}, retryableError{errRendererFault}
+			}
+			return emulatorInstance{}, retryableError{exitError{
+				status:    exitStatus(err),
+				tail:      outputTail(output.String(), exitOutputTail),
+				signature: findCrashSignature(output.String()),
+			}}
+		case <-timeoutTimer.C:
+			// Include error before and after printing the emulator log because it's so long
+			errorMsg := fmt.Sprintf("Device detection phase timed out: the device did not come online in adb within %d seconds.", attempt.timeout/time.Second)
+			log.Errorf(errorMsg)
+			printEmulatorLog(output.String(), attempt)
+			if err := killEmulator(); err != nil {
+				log.Warnf("Couldn't finish emulator process: %v", err)
+			}
+			if lastState == "" {
+				return emulatorInstance{}, fmt.Errorf("device detection phase timed out: the device did not show up in adb within %d seconds", attempt.timeout/time.Second)
+			}
+			return emulatorInstance{}, fmt.Errorf("device detection phase timed out: the device did not come online in adb within %d seconds, it was stuck in the %s state", attempt.timeout/time.Second, lastState)
+		case <-deviceCheckTicker.C:
+			polls++
+			if attempt.polling.restartRequired(polls) {
+				log.Warnf("Device is not online after %d checks, restarting the adb server", polls)
+				if err := attempt.adbClient.RestartServer(); err != nil {
+					log.Warnf("Failed to restart adb server: %s", err)
+				}
+			}
+			serial, state, err := queryNewDevice(attempt.adbClient, attempt.runningDevices, attempt.expectedSerial)
+			if err != nil {
+				return emulatorInstance{}, err
+			} else if state == "device" {
+				online = true
+				return emulatorInstance{
+					serial:  serial,
+					process: deviceStartCmd.GetCmd().Process,
+					exited:  channelExitWaiter(emulatorExitedCh),
+					output:  &output,
+				}, nil
+			}
+			if state != lastState {
+				if state != "" {
+					log.Printf("Device (%s) is %s", serial, state)
+				}
+				lastState, stateSince = state, polls
+			}
+			if time.Since(lastProgressReport) >= detectionProgressInterval {
+				lastProgressReport = time.Now()
+				log.Printf("Still waiting for the device, %s elapsed, %s", time.Since(detectionStart).Round(time.Second), detectionStatus(serial, state))
+			}
+			switch statePolls := polls - stateSince; {
+			case state == "offline" && statePolls > 0 && statePolls%offlineReconnectPolls == 0:
+				log.Warnf("Device (%s) is offline for %d checks, reconnecting it", serial, statePolls)
+				if _, err := attempt.adbClient.Exec(ADBServerTimeout, "reconnect", "offline"); err != nil {
+					log.Warnf("Failed to reconnect offline devices: %s", err)
+				}
+			case state == "unauthorized" && statePolls >= unauthorizedPolls:
+				log.Errorf("Device (%s) is unauthorized", serial)
+				if err := killEmulator(); err != nil {
+					log.Warnf("Couldn't finish emulator process: %v", err)
+				}
+				return emulatorInstance{}, fmt.Errorf("device (%s) is unauthorized: adbd on the device doesn't accept the adb key of the host, "+
+					"make sure the key pair in ~/.android (adbkey, adbkey.pub) is readable or set ADB_VENDOR_KEYS, then restart the adb server", serial)
+			}
+			if containsAny(output.String(), corruptionIndicators) {
+				log.Warnf("Emulator log contains AVD corruption")
+				printEmulatorLog(output.String(), attempt)
+				if err := killEmulator(); err != nil {
+					return emulatorInstance{}, fmt.Errorf("couldn't finish emulator process: %v", err)
+				}
+				return emulatorInstance{}, retryableError{errCorruptAVD}
+			}
+			if err := findFatalFault(output.String()); err != nil {
+				log.Errorf("Emulator log contains fatal fault")
+				printEmulatorLog(output.String(), attempt)
+				if killErr := killEmulator(); killErr != nil {
+					log.Warnf("Couldn't finish emulator process: %v", killErr)
+				}
+				return emulatorInstance{}, err
+			}
+			if containsAny(output.String(), rendererFaultIndicators) {
+				log.Warnf("Emulator log contains GPU emulation fault")
+				printEmulatorLog(output.String(), attempt)
+				if err := killEmulator(); err != nil {
+					return emulatorInstance{}, fmt.Errorf("couldn't finish emulator process: %v", err)
+				}
+				return emulatorInstance{}, retryableError{errRendererFault}
+			}
+			if containsAny(output.String(), faultIndicators) {
+				log.Warnf("Emulator log contains fault")
+				printEmulatorLog(output.String(), attempt)
+				if err := killEmulator(); err != nil {
+					return emulatorInstance{}, fmt.Errorf("couldn't finish emulator process: %v", err)
+				}
+				return emulatorInstance{}, retryableError{errEmulatorFault}
+			}
+		}
+	}
+}
+
+func containsAny(output string, any []string) bool {
+	for _, fault := range any {
+		if strings.Contains(output, fault) {
+			return true
+		}
+	}
+
+	return false
+}
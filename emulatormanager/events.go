@@ -0,0 +1,48 @@
+package emulatormanager
+
+import (
+	"errors"
+	"time"
+)
+
+// Event types reported by Boot.
+const (
+	EventProcessStarted   = "process_started"
+	EventDeviceDetected   = "device_detected"
+	EventFaultMatched     = "fault_matched"
+	EventRestartTriggered = "restart_triggered"
+	EventBootCompleted    = "boot_completed"
+	EventBootFailed       = "boot_failed"
+)
+
+// Event is a lifecycle event of an emulator boot.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"event"`
+	AVDName string    `json:"avd_name"`
+	Serial  string    `json:"serial,omitempty"`
+	Attempt int       `json:"attempt,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// emit reports the event of the given boot attempt to the OnEvent callback.
+func (opts BootOptions) emit(eventType string, attempt int, serial, message string) {
+	if opts.OnEvent == nil {
+		return
+	}
+	opts.OnEvent(Event{
+		Time:    time.Now(),
+		Type:    eventType,
+		AVDName: opts.AVDName,
+		Serial:  serial,
+		Attempt: attempt,
+		Message: message,
+	})
+}
+
+// isFault tells if the boot attempt failed because a known fault was recognized in the emulator log or the boot.
+func isFault(err error) bool {
+	var fatalErr fatalFaultError
+	return errors.Is(err, errCorruptAVD) || errors.Is(err, errRendererFault) || errors.Is(err, errEmulatorFault) ||
+		errors.Is(err, ErrBootStuck) || errors.As(err, &fatalErr)
+}
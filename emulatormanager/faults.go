@@ -0,0 +1,61 @@
+package emulatormanager
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// fatalFault is an emulator log signature of a hard failure which is not fixed by restarting the emulator.
+type fatalFault struct {
+	pattern     *regexp.Regexp
+	description string
+	remediation string
+}
+
+var fatalFaults = []fatalFault{
+	{
+		pattern:     regexp.MustCompile(`PANIC: Unknown AVD name \[[^\]]*\]`),
+		description: "the AVD doesn't exist",
+		remediation: "Check the AVD name and the AVD home (ANDROID_AVD_HOME), `emulator -list-avds` lists the available AVDs.",
+	},
+	{
+		pattern:     regexp.MustCompile(`PANIC: Missing emulator engine program`),
+		description: "the emulator engine (qemu) binary is missing",
+		remediation: "Reinstall the emulator package (`sdkmanager emulator`) or select another emulator channel.",
+	},
+	{
+		pattern:     regexp.MustCompile(`x86(_64)? emulation currently requires hardware acceleration`),
+		description: "hardware acceleration is not available for the x86 system image",
+		remediation: "Enable KVM (Linux) or the Hypervisor.framework (macOS) on the host, or use an ARM system image.",
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)(failed to initialize vulkan|vkCreateInstance failed|VK_ERROR_INCOMPATIBLE_DRIVER)`),
+		description: "the Vulkan graphics backend failed to initialize",
+		remediation: "Disable Vulkan by adding `-feature -Vulkan` to the Start AVD command flags input, or select another GPU mode.",
+	},
+	{
+		pattern:     regexp.MustCompile(`qemu-system-\S+ exited with status \d+`),
+		description: "the emulator engine (qemu) process exited",
+		remediation: "Check the emulator log above for the root cause, the system image or the AVD configuration might be incompatible with the host.",
+	},
+}
+
+// fatalFaultError is returned when the emulator log contains a fatal fault.
+type fatalFaultError struct {
+	fault fatalFault
+	match string
+}
+
+func (e fatalFaultError) Error() string {
+	return fmt.Sprintf("%s (%s). %s", e.fault.description, e.match, e.fault.remediation)
+}
+
+// findFatalFault returns the error describing the first fatal fault found in the emulator output, or nil.
+func findFatalFault(output string) error {
+	for _, fault := range fatalFaults {
+		if match := fault.pattern.FindString(output); match != "" {
+			return fatalFaultError{fault: fault, match: match}
+		}
+	}
+	return nil
+}
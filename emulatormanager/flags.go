@@ -0,0 +1,70 @@
+package emulatormanager
+
+import (
+	"strings"
+)
+
+// StartFlag is a single emulator command line flag with its optional values.
+type StartFlag struct {
+	name   string
+	values []string
+}
+
+func (f StartFlag) args() []string {
+	return append([]string{f.name}, f.values...)
+}
+
+// DefaultStartFlags are the flags the emulator is started with unless the user disables or overrides them.
+var DefaultStartFlags = []StartFlag{
+	{name: "-verbose"},
+	{name: "-show-kernel"},
+	{name: "-no-audio"},
+	{name: "-no-window"},
+	{name: "-no-boot-anim"},
+	{name: "-netdelay", values: []string{"none"}},
+	{name: "-netspeed", values: []string{"full"}},
+	{name: "-no-snapshot"},
+	{name: "-wipe-data"},
+	{name: "-gpu", values: []string{"auto"}},
+}
+
+// flagNames returns the flag names (arguments starting with a dash) found in the given argument list.
+func flagNames(args []string) map[string]bool {
+	names := map[string]bool{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			names[arg] = true
+		}
+	}
+	return names
+}
+
+// StartArgs merges the default flags with the user supplied ones.
+// A default flag is dropped if it is listed in disabledFlags or if the user passes the same flag in customFlags,
+// so user supplied flags always win over the built-in ones.
+func StartArgs(avdName string, defaults []StartFlag, disabledFlags, customFlags []string) []string {
+	disabled := flagNames(disabledFlags)
+	overridden := flagNames(customFlags)
+
+	args := []string{"@" + avdName}
+	for _, flag := range defaults {
+		if disabled[flag.name] || overridden[flag.name] {
+			continue
+		}
+		args = append(args, flag.args()...)
+	}
+
+	return append(args, customFlags...)
+}
+
+// WithFlagValues returns a copy of flags where the values of the named flag are replaced.
+func WithFlagValues(flags []StartFlag, name string, values ...string) []StartFlag {
+	var updated []StartFlag
+	for _, flag := range flags {
+		if flag.name == name {
+			flag.values = values
+		}
+		updated = append(updated, flag)
+	}
+	return updated
+}
@@ -0,0 +1,62 @@
+package emulatormanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStartArgs(t *testing.T) {
+	defaults := []StartFlag{
+		{name: "-verbose"},
+		{name: "-no-window"},
+		{name: "-gpu", values: []string{"auto"}},
+	}
+	tests := []struct {
+		name          string
+		disabledFlags []string
+		customFlags   []string
+		want          []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"@emulator", "-verbose", "-no-window", "-gpu", "auto"},
+		},
+		{
+			name:          "disabled flag is dropped",
+			disabledFlags: []string{"-no-window"},
+			want:          []string{"@emulator", "-verbose", "-gpu", "auto"},
+		},
+		{
+			name:        "custom flag overrides the default",
+			customFlags: []string{"-gpu", "host"},
+			want:        []string{"@emulator", "-verbose", "-no-window", "-gpu", "host"},
+		},
+		{
+			name:          "custom flags are appended",
+			disabledFlags: []string{"-verbose"},
+			customFlags:   []string{"-memory", "4096"},
+			want:          []string{"@emulator", "-no-window", "-gpu", "auto", "-memory", "4096"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StartArgs("emulator", defaults, tt.disabledFlags, tt.customFlags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StartArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFlagValues(t *testing.T) {
+	flags := []StartFlag{{name: "-netdelay", values: []string{"none"}}, {name: "-gpu", values: []string{"auto"}}}
+
+	got := StartArgs("emulator", WithFlagValues(flags, "-gpu", "swiftshader_indirect"), nil, nil)
+	want := []string{"@emulator", "-netdelay", "none", "-gpu", "swiftshader_indirect"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StartArgs() = %v, want %v", got, want)
+	}
+	// the original flags are left unchanged
+	if flags[1].values[0] != "auto" {
+		t.Errorf("WithFlagValues() modified the original flags: %v", flags)
+	}
+}
@@ -0,0 +1,105 @@
+package emulatormanager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormFactor is the device type of the system image, which determines how the readiness of the device is checked.
+type FormFactor string
+
+const (
+	FormFactorPhone      FormFactor = "phone"
+	FormFactorWear       FormFactor = "wear"
+	FormFactorTV         FormFactor = "tv"
+	FormFactorAutomotive FormFactor = "automotive"
+)
+
+// ParseFormFactor returns the form factor of the input, auto detects it from the system image tag.
+func ParseFormFactor(input, tag string) (FormFactor, error) {
+	switch input {
+	case "", "auto":
+		switch {
+		case strings.Contains(tag, "wear"):
+			return FormFactorWear, nil
+		case strings.Contains(tag, "tv"):
+			return FormFactorTV, nil
+		case strings.Contains(tag, "automotive"):
+			return FormFactorAutomotive, nil
+		}
+		return FormFactorPhone, nil
+	case string(FormFactorPhone), string(FormFactorWear), string(FormFactorTV), string(FormFactorAutomotive):
+		return FormFactor(input), nil
+	}
+	return "", fmt.Errorf("unknown form factor: %s", input)
+}
+
+// systemFeature returns the package manager feature identifying the form factor, phones have no such feature.
+func (f FormFactor) systemFeature() string {
+	switch f {
+	case FormFactorWear:
+		return "android.hardware.type.watch"
+	case FormFactorTV:
+		return "android.software.leanback"
+	case FormFactorAutomotive:
+		return "android.hardware.type.automotive"
+	}
+	return ""
+}
+
+// extraBootTime is added to the boot completion timeout, as these images run longer first boot sequences
+// (for example the setup of the TV launcher or the car service).
+func (f FormFactor) extraBootTime() time.Duration {
+	switch f {
+	case FormFactorWear:
+		return time.Duration(2) * time.Minute
+	case FormFactorTV, FormFactorAutomotive:
+		return time.Duration(5) * time.Minute
+	}
+	return 0
+}
+
+// homeScreenPending checks if the launcher of a non-phone form factor is not in the foreground yet:
+// these devices report a completed boot while their launcher is still being set up.
+func homeScreenPending(adbClient ADB, serial string) bool {
+	out, err := adbClient.Shell(serial, "cmd", "package", "resolve-activity", "--brief", "-a", "android.intent.action.MAIN", "-c", "android.intent.category.HOME")
+	if err != nil {
+		return true
+	}
+	lines := strings.Split(out, "\n")
+	component := strings.TrimSpace(lines[len(lines)-1])
+	launcherPackage := strings.Split(component, "/")[0]
+	if launcherPackage == "" {
+		return true
+	}
+
+	focus, err := adbClient.Shell(serial, "dumpsys", "window", "windows")
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(focus, "\n") {
+		if strings.Contains(line, "mCurrentFocus") && strings.Contains(line, launcherPackage) {
+			return false
+		}
+	}
+	return true
+}
+
+// featurePending checks if the system feature of the form factor is not reported by the package manager yet.
+func featurePending(adbClient ADB, serial, feature string) bool {
+	out, err := adbClient.Shell(serial, "pm", "list", "features")
+	return err != nil || !strings.Contains(out, "feature:"+feature)
+}
+
+// IsPlayStoreImage tells if the system image is a Play Store (production) build: these images can't run adbd as root
+// and finish their boot once the Google Play services are set up, without a signed in account.
+func IsPlayStoreImage(tag string) bool {
+	return strings.HasSuffix(tag, "playstore")
+}
+
+// playServicesPending checks if the Google Play services package is not available yet.
+func playServicesPending(adbClient ADB, serial string) bool {
+	out, err := adbClient.Shell(serial, "pm", "path", "com.google.android.gms")
+	return err != nil || !strings.HasPrefix(out, "package:")
+}
@@ -0,0 +1,70 @@
+package emulatormanager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// errRendererFault is returned when the emulator log shows that the GPU emulation could not be initialized.
+var errRendererFault = errors.New("emulator failed to initialize GPU emulation")
+
+// rendererFaultIndicators are emulator log lines signaling a broken GPU emulation mode.
+var rendererFaultIndicators = []string{
+	"Could not initialize OpenglES emulation",
+	"Failed to initialize GPU emulation",
+	"Failed to create Context",
+	"emuglConfig_init: GPU emulation failed",
+	"Failed to initialize renderer",
+	"eglMakeCurrent failed",
+	"Could not initialize emulated framebuffer",
+}
+
+// gpuModeFallbacks is the order in which the GPU modes are tried if the emulator fails to initialize the renderer.
+var gpuModeFallbacks = []string{"host", "auto", "angle_indirect", "swiftshader_indirect", "guest"}
+
+// gpuModeChain iterates over the selected GPU mode and the modes it can fall back to.
+type gpuModeChain struct {
+	modes   []string
+	current int
+}
+
+// newGPUModeChain returns a chain starting with the given mode followed by the modes after it in gpuModeFallbacks.
+func newGPUModeChain(mode string) *gpuModeChain {
+	modes := []string{mode}
+	for i, fallback := range gpuModeFallbacks {
+		if fallback == mode {
+			modes = append(modes, gpuModeFallbacks[i+1:]...)
+			break
+		}
+	}
+	return &gpuModeChain{modes: modes}
+}
+
+func (c *gpuModeChain) mode() string {
+	return c.modes[c.current]
+}
+
+// next moves to the next fallback mode, it returns false if there is nothing left to fall back to.
+func (c *gpuModeChain) next() bool {
+	if c.current+1 >= len(c.modes) {
+		return false
+	}
+	c.current++
+	return true
+}
+
+// gpuFallback switches to the next GPU mode after a renderer fault of the current mode.
+// The returned error is retryable only if the next attempt runs with another GPU mode.
+func gpuFallback(gpuModes *gpuModeChain, overridden bool, err error) error {
+	gpuMode := gpuModes.mode()
+	if overridden {
+		return fmt.Errorf("%s with the -gpu flag of the start command flags, remove the flag to let the step fall back to other GPU modes", errRendererFault)
+	}
+	if !gpuModes.next() {
+		return fmt.Errorf("%s with GPU mode %s, no more GPU modes to fall back to", errRendererFault, gpuMode)
+	}
+	log.Warnf("GPU mode %s failed, falling back to %s", gpuMode, gpuModes.mode())
+	return fmt.Errorf("%w (GPU mode: %s)", err, gpuMode)
+}
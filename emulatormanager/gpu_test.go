@@ -0,0 +1,32 @@
+package emulatormanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGPUModeChain(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{mode: "host", want: []string{"host", "auto", "angle_indirect", "swiftshader_indirect", "guest"}},
+		{mode: "auto", want: []string{"auto", "angle_indirect", "swiftshader_indirect", "guest"}},
+		{mode: "swiftshader_indirect", want: []string{"swiftshader_indirect", "guest"}},
+		{mode: "guest", want: []string{"guest"}},
+		// a mode without fallbacks is tried alone
+		{mode: "off", want: []string{"off"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			chain := newGPUModeChain(tt.mode)
+			got := []string{chain.mode()}
+			for chain.next() {
+				got = append(got, chain.mode())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newGPUModeChain(%q) modes = %v, want %v", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
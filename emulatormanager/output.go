@@ -0,0 +1,269 @@
+package emulatormanager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to be written by the emulator output copying goroutine while being read.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+const (
+	emulatorLogMaxSize  = 50 * 1024 * 1024
+	emulatorLogMaxFiles = 3
+)
+
+// rotatingFile is a log file which is moved to <path>.1, <path>.2, ... once it reaches maxSize,
+// keeping at most maxFiles rotated files.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	for i := f.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// LogVerbosity controls how much of the emulator output is printed when a boot attempt fails.
+type LogVerbosity string
+
+const (
+	// LogQuiet prints the tail of the emulator output without the kernel and verbose emulator lines.
+	LogQuiet LogVerbosity = "quiet"
+	// LogNormal prints the emulator output without the kernel lines.
+	LogNormal LogVerbosity = "normal"
+	// LogDebug prints the complete emulator output.
+	LogDebug LogVerbosity = "debug"
+)
+
+// quietLogTail is the number of emulator log lines printed in quiet mode
+const quietLogTail = 50
+
+var (
+	// kernelLogLinePattern matches the kernel messages printed due to -show-kernel, like: [    1.234567] init: ...
+	kernelLogLinePattern = regexp.MustCompile(`^\s*\[\s*\d+\.\d+\]`)
+	// taggedLogLinePattern matches the lines written by taggedLineWriter, like: [+  12.345s emulator-stdout] ...
+	taggedLogLinePattern = regexp.MustCompile(`^\[\+\s*\d+\.\d+s ([a-z-]+)\] (.*)$`)
+	// verboseLogLinePattern matches the messages printed due to -verbose, like: emulator: ... or VERBOSE | ...
+	verboseLogLinePattern = regexp.MustCompile(`^(emulator: |VERBOSE\s*\||DEBUG\s*\|)`)
+)
+
+// filterEmulatorLog drops the noisy lines of the emulator output according to the verbosity,
+// the omitted lines are summarized at the end.
+func filterEmulatorLog(output string, verbosity LogVerbosity) string {
+	if verbosity == LogDebug {
+		return output
+	}
+
+	var lines []string
+	kernelLines, verboseLines := 0, 0
+	for _, line := range strings.Split(output, "\n") {
+		tag, message := splitLogLineTag(line)
+		switch {
+		case tag == kernelTag:
+			kernelLines++
+		case verbosity == LogQuiet && verboseLogLinePattern.MatchString(message):
+			verboseLines++
+		default:
+			lines = append(lines, line)
+		}
+	}
+
+	omitted := 0
+	if verbosity == LogQuiet && len(lines) > quietLogTail {
+		omitted = len(lines) - quietLogTail
+		lines = lines[omitted:]
+	}
+
+	var summary []string
+	if kernelLines > 0 {
+		summary = append(summary, fmt.Sprintf("%d kernel", kernelLines))
+	}
+	if verboseLines > 0 {
+		summary = append(summary, fmt.Sprintf("%d verbose", verboseLines))
+	}
+	if omitted > 0 {
+		summary = append(summary, fmt.Sprintf("%d earlier", omitted))
+	}
+	filtered := strings.Join(lines, "\n")
+	if len(summary) > 0 {
+		filtered += fmt.Sprintf("\n(%s line(s) omitted)", strings.Join(summary, ", "))
+	}
+	return filtered
+}
+
+// exitOutputTail is the number of emulator output lines reported when the emulator exits unexpectedly
+const exitOutputTail = 100
+
+// outputTail returns the last n non-empty lines of the emulator output without the kernel messages,
+// as the reason of a qemu exit is printed by the emulator itself.
+func outputTail(output string, n int) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if tag, _ := splitLogLineTag(line); tag == kernelTag || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Source tags of the emulator output lines.
+const (
+	emulatorStdoutTag = "emulator-stdout"
+	emulatorStderrTag = "emulator-stderr"
+	kernelTag         = "kernel"
+	adbTag            = "adb"
+)
+
+// clockStart is the reference point of the log line timestamps, so the lines of concurrent emulators,
+// their boot attempts and the adb commands can be ordered.
+var clockStart = time.Now()
+
+// logTimestamp returns the monotonic time elapsed since clockStart, like: +  12.345s
+func logTimestamp() string {
+	return fmt.Sprintf("+%8.3fs", time.Since(clockStart).Seconds())
+}
+
+// splitLogLineTag returns the source tag and the message of a line written by taggedLineWriter,
+// the tag is empty for other lines.
+func splitLogLineTag(line string) (string, string) {
+	if matches := taggedLogLinePattern.FindStringSubmatch(line); matches != nil {
+		return matches[1], matches[2]
+	}
+	return "", line
+}
+
+// taggedLineWriter prefixes every line of an output stream with a timestamp and the source tag of the stream,
+// the kernel messages of the emulator are tagged as kernel.
+type taggedLineWriter struct {
+	mu      sync.Mutex
+	tag     string
+	out     io.Writer
+	partial []byte
+}
+
+func newTaggedLineWriter(tag string, out io.Writer) *taggedLineWriter {
+	return &taggedLineWriter{tag: tag, out: out}
+}
+
+func (w *taggedLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.partial[:i])
+		w.partial = w.partial[i+1:]
+		if err := w.writeLine(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes the last, not newline terminated line of the stream.
+func (w *taggedLineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.partial) == 0 {
+		return nil
+	}
+	line := string(w.partial)
+	w.partial = nil
+	return w.writeLine(line)
+}
+
+func (w *taggedLineWriter) writeLine(line string) error {
+	line = strings.TrimSuffix(line, "\r")
+	tag := w.tag
+	if kernelLogLinePattern.MatchString(line) {
+		tag = kernelTag
+	}
+	_, err := fmt.Fprintf(w.out, "[%s %s] %s\n", logTimestamp(), tag, line)
+	return err
+}
@@ -0,0 +1,85 @@
+package emulatormanager
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the emulator console port range, see: https://developer.android.com/studio/run/emulator-commandline#common
+	FirstEmulatorPort = 5554
+	LastEmulatorPort  = 5682
+)
+
+// PortRange is an inclusive range of emulator console ports.
+type PortRange struct {
+	First, Last int
+}
+
+// ParsePortRange parses the port input: a single console port (5560) or a range of console ports (5560-5600).
+// For a single port, count consecutive console ports are reserved starting from it.
+func ParsePortRange(spec string, count int) (PortRange, error) {
+	var r PortRange
+	if firstStr, lastStr := splitRange(spec); lastStr != "" {
+		first, err := strconv.Atoi(firstStr)
+		if err != nil {
+			return PortRange{}, fmt.Errorf("invalid first port (%s): %s", firstStr, err)
+		}
+		last, err := strconv.Atoi(lastStr)
+		if err != nil {
+			return PortRange{}, fmt.Errorf("invalid last port (%s): %s", lastStr, err)
+		}
+		r = PortRange{First: first, Last: last}
+	} else {
+		port, err := strconv.Atoi(spec)
+		if err != nil {
+			return PortRange{}, fmt.Errorf("invalid port (%s): %s", spec, err)
+		}
+		r = PortRange{First: port, Last: port + 2*(count-1)}
+	}
+
+	if r.First%2 != 0 {
+		return PortRange{}, fmt.Errorf("console port must be an even number: %d", r.First)
+	}
+	if r.First < FirstEmulatorPort || r.Last > LastEmulatorPort || r.First > r.Last {
+		return PortRange{}, fmt.Errorf("console ports must be within %d-%d: %d-%d", FirstEmulatorPort, LastEmulatorPort, r.First, r.Last)
+	}
+	return r, nil
+}
+
+func splitRange(spec string) (string, string) {
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return spec, ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+// isPortFree checks whether the port can be bound on the loopback interface.
+func isPortFree(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	return listener.Close() == nil
+}
+
+// AllocatePorts returns count free console ports (the adb port is the console port + 1) within the range.
+func AllocatePorts(runningDevices map[string]string, count int, r PortRange) ([]int, error) {
+	var ports []int
+	for port := r.First; port <= r.Last && len(ports) < count; port += 2 {
+		if _, running := runningDevices[fmt.Sprintf("emulator-%d", port)]; running {
+			continue
+		}
+		if !isPortFree(port) || !isPortFree(port+1) {
+			continue
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) < count {
+		return nil, fmt.Errorf("only %d free emulator port(s) found in %d-%d, %d required", len(ports), r.First, r.Last, count)
+	}
+	return ports, nil
+}
@@ -0,0 +1,37 @@
+package emulatormanager
+
+import "testing"
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		count   int
+		want    PortRange
+		wantErr bool
+	}{
+		{name: "single port", spec: "5560", count: 1, want: PortRange{First: 5560, Last: 5560}},
+		{name: "single port reserves count ports", spec: "5560", count: 3, want: PortRange{First: 5560, Last: 5564}},
+		{name: "range", spec: "5560-5600", count: 1, want: PortRange{First: 5560, Last: 5600}},
+		{name: "range with spaces", spec: "5560 - 5600", count: 1, want: PortRange{First: 5560, Last: 5600}},
+		{name: "whole console range", spec: "5554-5682", count: 1, want: PortRange{First: FirstEmulatorPort, Last: LastEmulatorPort}},
+		{name: "odd port", spec: "5555", count: 1, wantErr: true},
+		{name: "below the console range", spec: "5552", count: 1, wantErr: true},
+		{name: "reserved ports above the console range", spec: "5680", count: 3, wantErr: true},
+		{name: "reversed range", spec: "5600-5560", count: 1, wantErr: true},
+		{name: "invalid port", spec: "port", count: 1, wantErr: true},
+		{name: "invalid first port", spec: "x-5600", count: 1, wantErr: true},
+		{name: "invalid last port", spec: "5560-x", count: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePortRange(tt.spec, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePortRange(%q, %d) error = %v, wantErr %v", tt.spec, tt.count, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePortRange(%q, %d) = %+v, want %+v", tt.spec, tt.count, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+// +build !windows
+
+package emulatormanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// StartInProcessGroup makes the command the leader of a new process group, the ID of the group is the PID of the command.
+func StartInProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends the signal to every process in the process group led by the emulator process,
+// so the qemu child processes receive it too. The emulator is started in its own process group for this reason.
+func signalProcessGroup(process *os.Process, sig syscall.Signal) error {
+	err := syscall.Kill(-process.Pid, sig)
+	if err == syscall.ESRCH {
+		// the process group is already gone
+		return nil
+	}
+	return err
+}
+
+// KillProcesses sends SIGKILL to every process whose command line matches the pattern.
+func KillProcesses(pattern string) error {
+	err := command.New("pkill", "-KILL", "-f", pattern).Run()
+	var exitErr *exec.ExitError
+	// pkill exits with 1 if no process matched
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil
+	}
+	return err
+}
+
+// ProcessStartTime returns the start time of the process as reported by ps, it tells apart a reused PID.
+func ProcessStartTime(pid int) (string, error) {
+	cmd := command.New("ps", "-o", "lstart=", "-p", strconv.Itoa(pid))
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+	return out, nil
+}
+
+// processAlive tells if the process still exists, the signal 0 only checks the permission to signal it.
+func processAlive(process *os.Process) bool {
+	return process.Signal(syscall.Signal(0)) == nil
+}
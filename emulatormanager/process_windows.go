@@ -0,0 +1,71 @@
+//go:build windows
+// +build windows
+
+package emulatormanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// taskkillNotFoundExitCode is the exit code of taskkill if the process doesn't exist.
+const taskkillNotFoundExitCode = 128
+
+// StartInProcessGroup makes the command the root of a new process group, so it doesn't receive the console signals of the step.
+func StartInProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcessGroup stops the emulator process and its qemu child processes with taskkill (Windows has no signals):
+// SIGKILL forcefully terminates the process tree, any other signal asks the processes to close.
+func signalProcessGroup(process *os.Process, sig syscall.Signal) error {
+	args := []string{"/T", "/PID", strconv.Itoa(process.Pid)}
+	if sig == syscall.SIGKILL {
+		args = append([]string{"/F"}, args...)
+	}
+	out, err := command.New("taskkill", args...).RunAndReturnTrimmedCombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == taskkillNotFoundExitCode {
+		// the process tree is already gone
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("taskkill failed: %s, output: %s", err, out)
+	}
+	return nil
+}
+
+// KillProcesses forcefully terminates every process whose command line matches the pattern (a regular expression).
+// The command line of the PowerShell process contains the pattern too, so it is left out of the match.
+func KillProcesses(pattern string) error {
+	script := fmt.Sprintf("Get-CimInstance Win32_Process | Where-Object { $_.ProcessId -ne $PID -and $_.CommandLine -match '%s' } | ForEach-Object { Stop-Process -Id $_.ProcessId -Force }",
+		strings.ReplaceAll(pattern, "'", "''"))
+	out, err := command.New("powershell", "-NoProfile", "-NonInteractive", "-Command", script).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to kill processes (%s): %s, output: %s", pattern, err, out)
+	}
+	return nil
+}
+
+// ProcessStartTime returns the start time of the process as reported by PowerShell, it tells apart a reused PID.
+func ProcessStartTime(pid int) (string, error) {
+	script := fmt.Sprintf("(Get-Process -Id %d -ErrorAction Stop).StartTime.ToUniversalTime().ToString('o')", pid)
+	out, err := command.New("powershell", "-NoProfile", "-NonInteractive", "-Command", script).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the start time of process (%d): %s, output: %s", pid, err, out)
+	}
+	return out, nil
+}
+
+// processAlive tells if the process still exists, tasklist prints a message without the PID if it doesn't.
+func processAlive(process *os.Process) bool {
+	out, err := command.New("tasklist", "/nh", "/fi", fmt.Sprintf("PID eq %d", process.Pid)).RunAndReturnTrimmedCombinedOutput()
+	return err == nil && strings.Contains(out, " "+strconv.Itoa(process.Pid)+" ")
+}
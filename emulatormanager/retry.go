@@ -0,0 +1,92 @@
+package emulatormanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// retryableError marks a boot failure that might go away by starting the emulator again.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// RetryPolicy controls how many times and how often the emulator boot is attempted.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// delay returns the exponential backoff to wait after the given (1 based) failed attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// bootWithRetry calls boot until it succeeds, returns a non retryable error, the attempts run out or ctx is done.
+// onRetry is called with the failure of every attempt which is followed by another one.
+// The returned error lists the failure of every attempt.
+func bootWithRetry(ctx context.Context, policy RetryPolicy, onRetry func(attempt int, err error), boot func(attempt int) (string, error)) (string, error) {
+	var failures []string
+	var lastSignature *crashSignature
+	for attempt := 1; ; attempt++ {
+		serial, err := boot(attempt)
+		if err == nil {
+			return serial, nil
+		}
+
+		failures = append(failures, fmt.Sprintf("- attempt %d: %s", attempt, err))
+
+		// the same failure on consecutive attempts is deterministic, the remaining attempts would only burn time
+		signature := attemptCrashSignature(err)
+		if signature != nil && signature == lastSignature {
+			return "", fmt.Errorf("%w: %s on attempts %d and %d. %s\n%s", errCrashLoop, signature.name, attempt-1, attempt, signature.remediation, strings.Join(failures, "\n"))
+		}
+		lastSignature = signature
+
+		var retryErr retryableError
+		if !errors.As(err, &retryErr) || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return "", fmt.Errorf("boot failed after %d attempt(s):\n%s", attempt, strings.Join(failures, "\n"))
+		}
+
+		onRetry(attempt, err)
+		delay := policy.delay(attempt)
+		log.Warnf("Boot attempt %d/%d failed: %s", attempt, policy.MaxAttempts, err)
+		log.Warnf("Trying to start emulator process again in %s...", delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return "", fmt.Errorf("boot aborted after %d attempt(s): %w\n%s", attempt, err, strings.Join(failures, "\n"))
+		}
+	}
+}
+
+// sleepContext waits for the duration, it returns the context's error if ctx is done earlier.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
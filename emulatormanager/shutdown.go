@@ -0,0 +1,123 @@
+package emulatormanager
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	// emuKillGracePeriod is the time the emulator has to exit after the adb emu kill command
+	emuKillGracePeriod = time.Duration(30) * time.Second
+	// terminateGracePeriod is the time the emulator has to exit after a signal
+	terminateGracePeriod = time.Duration(10) * time.Second
+)
+
+// ExitWaiter reports whether the process exited within the timeout.
+type ExitWaiter func(timeout time.Duration) bool
+
+// channelExitWaiter returns an ExitWaiter of a child process, exitedCh is closed once the process is waited for.
+func channelExitWaiter(exitedCh <-chan struct{}) ExitWaiter {
+	return func(timeout time.Duration) bool {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-exitedCh:
+			return true
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// processExitPollInterval is how often ProcessExitWaiter checks the process
+const processExitPollInterval = time.Second
+
+// ProcessExitWaiter returns an ExitWaiter of a process which is not a child of the step, like an emulator
+// started by a previous run of the step: the process can't be waited for, so its existence is polled.
+func ProcessExitWaiter(process *os.Process) ExitWaiter {
+	return func(timeout time.Duration) bool {
+		deadline := time.Now().Add(timeout)
+		for processAlive(process) {
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(processExitPollInterval)
+		}
+		return true
+	}
+}
+
+// StopProcessGroup stops a detached process group started with StartInProcessGroup, like a helper process
+// started by a previous run of the step. The group is interrupted first, so its processes can finish their output
+// (screenrecord finalizes the recording on SIGINT), then it is terminated and finally killed if its leader doesn't exit in time.
+func StopProcessGroup(pgid int) error {
+	process, err := os.FindProcess(pgid)
+	if err != nil {
+		// the process is already gone
+		return nil
+	}
+	defer killLeftoverChildren(process)
+
+	exited := ProcessExitWaiter(process)
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		if err := signalProcessGroup(process, sig); err != nil {
+			log.Warnf("Failed to signal process group (%d): %s", pgid, err)
+		} else if exited(terminateGracePeriod) {
+			return nil
+		}
+	}
+
+	if err := signalProcessGroup(process, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("failed to kill process group (%d): %s", pgid, err)
+	}
+	if !exited(terminateGracePeriod) {
+		return fmt.Errorf("process group (%d) did not exit after SIGKILL", pgid)
+	}
+	return nil
+}
+
+// killLeftoverChildren kills the processes of the emulator process group which survived the emulator process,
+// so an orphan qemu process doesn't show up in the next detection round.
+func killLeftoverChildren(process *os.Process) {
+	if err := signalProcessGroup(process, syscall.SIGKILL); err != nil {
+		log.Warnf("Failed to kill leftover emulator processes (process group %d): %s", process.Pid, err)
+	}
+}
+
+// ShutdownEmulator stops the emulator gracefully: it asks the emulator to exit via adb (if the serial is known),
+// then escalates to SIGTERM and finally to SIGKILL of the emulator's process group if the process doesn't exit in time.
+func ShutdownEmulator(adbClient ADB, serial string, process *os.Process, exited ExitWaiter) error {
+	defer killLeftoverChildren(process)
+
+	if serial != "" {
+		if _, err := adbClient.Run(serial, "emu", "kill"); err != nil {
+			log.Warnf("Failed to kill emulator via adb: %s", err)
+		} else if exited(emuKillGracePeriod) {
+			return nil
+		}
+	}
+
+	log.Warnf("Terminating emulator process group (%d)", process.Pid)
+	if err := signalProcessGroup(process, syscall.SIGTERM); err != nil {
+		log.Warnf("Failed to terminate emulator process group: %s", err)
+	} else if exited(terminateGracePeriod) {
+		return nil
+	}
+
+	log.Warnf("Killing emulator process group (%d)", process.Pid)
+	if err := signalProcessGroup(process, syscall.SIGKILL); err != nil {
+		log.Warnf("Failed to kill emulator process group: %s", err)
+		if err := process.Kill(); err != nil {
+			return fmt.Errorf("failed to kill emulator process: %s", err)
+		}
+	}
+	if !exited(terminateGracePeriod) {
+		return fmt.Errorf("emulator process (%d) did not exit after SIGKILL", process.Pid)
+	}
+	return nil
+}
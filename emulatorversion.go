@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// emulatorVersionPattern matches the version in the emulator -version output, like:
+// Android emulator version 33.1.24.0 (build_id 10354687) (CL:N/A)
+var emulatorVersionPattern = regexp.MustCompile(`version (\d+(?:\.\d+)*)`)
+
+// emulatorVersionInputPattern matches a valid emulator_version input, like 33.1 or 33.1.24.0
+var emulatorVersionInputPattern = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// emulatorVersion returns the version of the emulator binary.
+func emulatorVersion(emulatorPath string) (string, error) {
+	cmd := command.New(emulatorPath, "-version")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+	matches := emulatorVersionPattern.FindStringSubmatch(out)
+	if matches == nil {
+		return "", fmt.Errorf("no version found in the emulator -version output: %s", out)
+	}
+	return matches[1], nil
+}
+
+// emulatorAcceptsFlag tells if the emulator lists the flag in its -help output, older emulators reject the flags they don't know.
+func emulatorAcceptsFlag(emulatorPath, flag string) (bool, error) {
+	cmd := command.New(emulatorPath, "-help")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+	for _, field := range strings.Fields(out) {
+		if field == flag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// emulatorVersionMatches checks if the installed version starts with the components of the pinned version,
+// so 33.1 matches 33.1.24.0 but not 33.10.1.0.
+func emulatorVersionMatches(installed, pinned string) bool {
+	return installed == pinned || strings.HasPrefix(installed, pinned+".")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEmulatorVersionMatches(t *testing.T) {
+	tests := []struct {
+		installed, pinned string
+		want              bool
+	}{
+		{installed: "33.1.24.0", pinned: "33.1", want: true},
+		{installed: "33.1.24.0", pinned: "33.1.24.0", want: true},
+		{installed: "33.10.1.0", pinned: "33.1"},
+		{installed: "32.1.15.0", pinned: "33"},
+	}
+	for _, tt := range tests {
+		if got := emulatorVersionMatches(tt.installed, tt.pinned); got != tt.want {
+			t.Errorf("emulatorVersionMatches(%s, %s) = %v, want %v", tt.installed, tt.pinned, got, tt.want)
+		}
+	}
+}
+
+func TestEmulatorAcceptsFlag(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake emulator is a shell script")
+	}
+	pth := filepath.Join(t.TempDir(), "emulator")
+	help := "#!/bin/sh\necho '    -no-metrics                    disable metrics reporting'\necho '    -no-window                     disable graphical window display'\n"
+	if err := ioutil.WriteFile(pth, []byte(help), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag string
+		want bool
+	}{
+		{flag: "-no-metrics", want: true},
+		{flag: "-no-window", want: true},
+		{flag: "-metrics-collection"},
+		{flag: "-no"},
+	}
+	for _, tt := range tests {
+		got, err := emulatorAcceptsFlag(pth, tt.flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("emulatorAcceptsFlag(%s) = %v, want %v", tt.flag, got, tt.want)
+		}
+	}
+}
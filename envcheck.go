@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// envCheck is a single check of the environment validation. A failing required check fails the step,
+// the others are reported as warnings.
+type envCheck struct {
+	name     string
+	required bool
+	// run returns the details printed in the table
+	run  func() (string, error)
+	hint string
+}
+
+// environmentChecks returns the checks of the SDK layout and the host the emulator runs on.
+func environmentChecks(androidHome string, cmdlineToolsPath string, emulatorRequired, accelerationRequired bool) []envCheck {
+	adbPath := emulatormanager.NewADB(androidHome).Path
+	return []envCheck{
+		{
+			name:     "Android SDK",
+			required: true,
+			run: func() (string, error) {
+				info, err := os.Stat(androidHome)
+				if err != nil {
+					return "", err
+				}
+				if !info.IsDir() {
+					return "", fmt.Errorf("%s is not a directory", androidHome)
+				}
+				return androidHome, nil
+			},
+			hint: "Set the ANDROID_HOME (or ANDROID_SDK_ROOT) environment variable to the Android SDK directory.",
+		},
+		{
+			name:     "platform-tools",
+			required: true,
+			run: func() (string, error) {
+				out, err := command.New(adbPath, "version").RunAndReturnTrimmedCombinedOutput()
+				if err != nil {
+					return "", fmt.Errorf("%s version failed: %s, output: %s", adbPath, err, out)
+				}
+				return strings.Split(out, "\n")[0], nil
+			},
+			hint: "Install the platform-tools package: sdkmanager platform-tools",
+		},
+		{
+			name:     "cmdline-tools",
+			required: true,
+			run: func() (string, error) {
+				if cmdlineToolsPath == "" {
+					return "", fmt.Errorf("not found in %s", androidHome)
+				}
+				for _, tool := range []string{"sdkmanager", "avdmanager"} {
+					if exists, err := pathutil.IsPathExists(cmdlineTool(cmdlineToolsPath, tool)); err != nil {
+						return "", err
+					} else if !exists {
+						return "", fmt.Errorf("%s not found in %s", tool, cmdlineToolsPath)
+					}
+				}
+				return cmdlineToolsPath, nil
+			},
+			hint: "Install the command-line tools package: sdkmanager \"cmdline-tools;latest\"",
+		},
+		{
+			name:     "emulator",
+			required: emulatorRequired,
+			run: func() (string, error) {
+				return emulatormanager.FindEmulator(androidHome)
+			},
+			hint: "Install the emulator package (set emulator_update to update or install_missing).",
+		},
+		{
+			name: "SDK licenses",
+			run: func() (string, error) {
+				pth := filepath.Join(androidHome, "licenses", "android-sdk-license")
+				if exists, err := pathutil.IsPathExists(pth); err != nil {
+					return "", err
+				} else if !exists {
+					return "", fmt.Errorf("%s not found", pth)
+				}
+				return "accepted", nil
+			},
+			hint: "Accept the SDK licenses: yes | sdkmanager --licenses",
+		},
+		{
+			name:     "acceleration",
+			required: accelerationRequired,
+			run: func() (string, error) {
+				if err := checkHostAcceleration(runtime.GOOS); err != nil {
+					return "", err
+				}
+				return "available", nil
+			},
+			hint: accelerationHint(runtime.GOOS),
+		},
+	}
+}
+
+// validateEnvironment runs every check and prints the results as a table. It returns the failed required checks
+// with their remediation hints.
+func validateEnvironment(checks []envCheck) error {
+	var failures []string
+	for _, check := range checks {
+		details, err := check.run()
+		status := "pass"
+		if err != nil {
+			status, details = "fail", err.Error()
+			if !check.required {
+				status = "warn"
+			}
+		}
+		line := fmt.Sprintf("| %-15s | %-4s | %s", check.name, status, details)
+		switch status {
+		case "pass":
+			log.Printf("%s", line)
+		case "warn":
+			log.Warnf("%s\n  Hint: %s", line, check.hint)
+		default:
+			log.Errorf("%s", line)
+			failures = append(failures, fmt.Sprintf("%s: %s\n  Hint: %s", check.name, details, check.hint))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d check(s) failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	return nil
+}
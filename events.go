@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const (
+	// eventsFileName is the name of the JSON lines event log in the deploy directory.
+	eventsFileName = "emulator-events.jsonl"
+	// eventDeviceReady is reported once the post-boot setup of the device is finished
+	eventDeviceReady = "device_ready"
+)
+
+// eventLog writes the emulator lifecycle events as JSON lines into the build log and the events file.
+// The events of the parallel boots are written by multiple goroutines.
+type eventLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newEventLog(deployDir string) (*eventLog, error) {
+	file, err := os.OpenFile(filepath.Join(deployDir, eventsFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLog{file: file}, nil
+}
+
+func (l *eventLog) emit(event emulatormanager.Event) {
+	if l == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Warnf("Failed to encode event: %s", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Println(string(line))
+	if _, err := fmt.Fprintln(l.file, string(line)); err != nil {
+		log.Warnf("Failed to write event log: %s", err)
+	}
+}
+
+// deviceReadyEvent reports that the device finished the post-boot setup.
+func deviceReadyEvent(result bootResult) emulatormanager.Event {
+	return emulatormanager.Event{Time: time.Now(), Type: eventDeviceReady, AVDName: result.AVDName, Serial: result.Serial}
+}
+
+func (l *eventLog) close() {
+	if l == nil {
+		return
+	}
+	if err := l.file.Close(); err != nil {
+		log.Warnf("Failed to close event log: %s", err)
+	}
+}
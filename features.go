@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// featureNamePattern matches an emulator feature, prefixed with a dash to disable it (like -Vulkan).
+var featureNamePattern = regexp.MustCompile(`^-?[A-Za-z0-9_]+$`)
+
+// featureFlags parses the comma or newline separated feature list into -feature flags.
+func featureFlags(list string) ([]string, error) {
+	var flags []string
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !featureNamePattern.MatchString(item) {
+			return nil, fmt.Errorf("invalid feature (%s), expected a feature name like RefCountPipe, or -Vulkan to disable a feature", item)
+		}
+		flags = append(flags, "-feature", item)
+	}
+	return flags, nil
+}
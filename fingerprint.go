@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const (
+	// fingerprintPIN is the screen lock PIN set up for the enrollment, fingerprints require a secure screen lock
+	fingerprintPIN = "1111"
+
+	// the enrollment activity asks for the finger multiple times
+	fingerprintEnrollTouches = 10
+	fingerprintTouchInterval = time.Duration(1) * time.Second
+	fingerprintUIDelay       = time.Duration(3) * time.Second
+)
+
+// enrollFingerprint returns a post-boot action which sets the screen lock PIN and enrolls the finger with the given id,
+// driving the enrollment activity with key events and console finger touches.
+func enrollFingerprint(fingerprintID int) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(adbClient emulatormanager.ADB, serial string) error {
+		if _, err := adbClient.Shell(serial, "locksettings", "set-pin", fingerprintPIN); err != nil {
+			return err
+		}
+		if _, err := adbClient.Shell(serial, "am", "start", "-a", "android.settings.FINGERPRINT_ENROLL"); err != nil {
+			return err
+		}
+		time.Sleep(fingerprintUIDelay)
+		// the enrollment starts by confirming the screen lock
+		if _, err := adbClient.Shell(serial, "input", "text", fingerprintPIN); err != nil {
+			return err
+		}
+		// KEYCODE_ENTER
+		if _, err := adbClient.Shell(serial, "input", "keyevent", "66"); err != nil {
+			return err
+		}
+		time.Sleep(fingerprintUIDelay)
+
+		client, err := emuconsole.DialSerial(serial)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				log.Warnf("Failed to close emulator console connection: %s", err)
+			}
+		}()
+		for i := 0; i < fingerprintEnrollTouches; i++ {
+			if err := client.FingerTouch(fingerprintID); err != nil {
+				return err
+			}
+			time.Sleep(fingerprintTouchInterval)
+			if err := client.FingerRemove(); err != nil {
+				return err
+			}
+			time.Sleep(fingerprintTouchInterval)
+		}
+
+		// KEYCODE_HOME leaves the enrollment activity
+		if _, err := adbClient.Shell(serial, "input", "keyevent", "3"); err != nil {
+			return err
+		}
+		if out, err := adbClient.Shell(serial, "dumpsys", "fingerprint"); err != nil {
+			log.Warnf("Failed to verify fingerprint enrollment: %s", err)
+		} else if !fingerprintEnrolled(out) {
+			log.Warnf("Fingerprint enrollment could not be verified, the enrollment flow of the image might differ")
+		}
+		return nil
+	}
+}
+
+// fingerprintEnrolled reports whether the dumpsys fingerprint output lists an enrolled fingerprint ("count":1 or higher).
+func fingerprintEnrolled(dumpsys string) bool {
+	const countKey = `"count":`
+	idx := strings.Index(dumpsys, countKey)
+	if idx < 0 {
+		return false
+	}
+	rest := dumpsys[idx+len(countKey):]
+	end := strings.IndexAny(rest, ",}")
+	if end < 0 {
+		return false
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(rest[:end]))
+	return err == nil && count > 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// forensicsOutputTail is the number of emulator output and dmesg lines kept in the forensics bundle.
+const forensicsOutputTail = 200
+
+// timeoutForensics collects a diagnostics bundle when the step fails because the overall timeout fired.
+type timeoutForensics struct {
+	ctx       context.Context
+	adbClient emulatormanager.ADB
+	deployDir string
+	avdNames  []string
+	// logPaths are the emulator log files of the AVDs, empty if the output is not saved
+	logPaths []string
+}
+
+// forensics is set once the overall timeout is armed, failf collects the bundle if the timeout fired.
+var forensics *timeoutForensics
+
+// fired tells if the overall timeout elapsed, an aborted build cancels the context instead.
+func (f *timeoutForensics) fired() bool {
+	return errors.Is(f.ctx.Err(), context.DeadlineExceeded)
+}
+
+// forensicsEntry is a file of the bundle, err is set if its content couldn't be collected.
+type forensicsEntry struct {
+	name    string
+	content string
+	err     error
+}
+
+// collect writes the bundle into the deploy directory and prints a summary of its entries.
+func (f *timeoutForensics) collect() {
+	fmt.Println()
+	log.Infof("Collecting timeout forensics")
+	// the step's context is done, the commands of the bundle get a fresh one
+	adbClient := f.adbClient.WithContext(context.Background())
+
+	var entries []forensicsEntry
+	devices, err := adbClient.Exec(emulatormanager.ADBServerTimeout, "devices", "-l")
+	entries = append(entries, forensicsEntry{name: "adb-devices.txt", content: devices, err: err})
+	processes, err := emulatorProcesses()
+	entries = append(entries, forensicsEntry{name: "processes.txt", content: processes, err: err})
+	if runtime.GOOS == "linux" {
+		dmesg, err := command.New("dmesg").RunAndReturnTrimmedCombinedOutput()
+		entries = append(entries, forensicsEntry{name: "dmesg.txt", content: lastLines(dmesg, forensicsOutputTail), err: err})
+	}
+	for i, avdName := range f.avdNames {
+		if f.logPaths[i] != "" {
+			content, err := ioutil.ReadFile(f.logPaths[i])
+			entries = append(entries, forensicsEntry{name: fmt.Sprintf("emulator-output-%s.txt", avdName), content: lastLines(string(content), forensicsOutputTail), err: err})
+		}
+		var config []byte
+		pth, err := avdConfigPath(avdName)
+		if err == nil {
+			config, err = ioutil.ReadFile(pth)
+		}
+		entries = append(entries, forensicsEntry{name: fmt.Sprintf("avd-config-%s.ini", avdName), content: string(config), err: err})
+	}
+
+	pth := filepath.Join(f.deployDir, fmt.Sprintf("emulator-timeout-forensics-%s.zip", time.Now().Format("20060102-150405")))
+	if err := writeForensicsBundle(pth, entries); err != nil {
+		log.Warnf("Failed to write timeout forensics bundle: %s", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.err != nil {
+			log.Printf("- %s: failed to collect: %s", entry.name, entry.err)
+		} else {
+			log.Printf("- %s: %d line(s)", entry.name, strings.Count(entry.content, "\n")+1)
+		}
+	}
+	if devices != "" {
+		log.Printf("adb devices:\n%s", devices)
+	}
+	log.Printf("Timeout forensics saved to: %s", pth)
+}
+
+// emulatorProcesses returns the state of the running emulator and qemu processes.
+func emulatorProcesses() (string, error) {
+	if runtime.GOOS == "windows" {
+		return command.New("tasklist", "/v", "/fi", "IMAGENAME eq emulator.exe").RunAndReturnTrimmedCombinedOutput()
+	}
+	out, err := command.New("ps", "-A", "-o", "pid,ppid,stat,etime,rss,args").RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return out, err
+	}
+	lines := strings.Split(out, "\n")
+	// the header is kept
+	processes := lines[:1]
+	for _, line := range lines[1:] {
+		if strings.Contains(line, "emulator") || strings.Contains(line, "qemu-system") {
+			processes = append(processes, line)
+		}
+	}
+	return strings.Join(processes, "\n"), nil
+}
+
+// lastLines returns the last n lines of the output.
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeForensicsBundle zips the entries into pth, an entry which couldn't be collected contains its error.
+func writeForensicsBundle(pth string, entries []forensicsEntry) error {
+	file, err := os.Create(pth)
+	if err != nil {
+		return err
+	}
+	if err := writeForensicsEntries(zip.NewWriter(file), entries); err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Warnf("Failed to close forensics bundle: %s", closeErr)
+		}
+		return err
+	}
+	return file.Close()
+}
+
+func writeForensicsEntries(archive *zip.Writer, entries []forensicsEntry) error {
+	for _, entry := range entries {
+		content := entry.content
+		if entry.err != nil {
+			content = fmt.Sprintf("failed to collect: %s\n%s", entry.err, content)
+		}
+		w, err := archive.Create(entry.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
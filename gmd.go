@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Gradle Managed Devices file names in the deploy directory.
+const (
+	managedDevicesJSONFileName    = "emulator-managed-devices.json"
+	managedDevicesSnippetFileName = "emulator-managed-devices.gradle.kts"
+)
+
+// managedDevice describes a booted emulator as a Gradle Managed Device definition.
+type managedDevice struct {
+	Name              string `json:"name"`
+	Device            string `json:"device"`
+	APILevel          int    `json:"api_level"`
+	SystemImageSource string `json:"system_image_source"`
+	ABI               string `json:"abi"`
+	Serial            string `json:"serial"`
+}
+
+// managedDeviceSystemImageSources maps the system image tags to the systemImageSource values of Gradle Managed Devices,
+// which has no Play Store source: the Play Store images are described with the Google APIs source.
+var managedDeviceSystemImageSources = map[string]string{
+	"default":               "aosp",
+	"aosp_atd":              "aosp-atd",
+	"google_apis":           "google",
+	"google_apis_playstore": "google",
+	"google_atd":            "google-atd",
+}
+
+// managedDeviceName returns the AVD name as a Gradle Managed Device name, which has to be a valid identifier:
+// the separated words are joined in camel case.
+func managedDeviceName(avdName string) string {
+	words := strings.FieldsFunc(avdName, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	var name strings.Builder
+	for i, word := range words {
+		if i == 0 {
+			name.WriteString(strings.ToLower(word))
+		} else {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	if name.Len() == 0 || unicode.IsDigit(rune(name.String()[0])) {
+		return "device" + name.String()
+	}
+	return name.String()
+}
+
+// deviceProfileNames returns the display names of the device profiles known by avdmanager by ID,
+// Gradle Managed Devices refer the profiles by their display name.
+func deviceProfileNames(avdManagerPath string) (map[string]string, error) {
+	cmd := command.New(avdManagerPath, "list", "device")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+	return parseDeviceProfileNames(out), nil
+}
+
+// parseDeviceProfileNames parses the avdmanager list device output, where each profile starts with an id line
+// (id: 19 or "pixel") followed by its name line (Name: Pixel).
+func parseDeviceProfileNames(out string) map[string]string {
+	names := map[string]string{}
+	id := ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "id:") {
+			id = ""
+			if start, end := strings.Index(line, `"`), strings.LastIndex(line, `"`); start != -1 && end > start {
+				id = line[start+1 : end]
+			}
+		} else if strings.HasPrefix(line, "Name:") && id != "" {
+			names[id] = strings.TrimSpace(strings.TrimPrefix(line, "Name:"))
+			id = ""
+		}
+	}
+	return names
+}
+
+// newManagedDevice returns the Gradle Managed Device definition of the booted emulator, or an error if the system image
+// tag has no Gradle Managed Devices equivalent. The profile ID is used if its display name is unknown.
+func newManagedDevice(result bootResult, tag string, profileNames map[string]string) (managedDevice, error) {
+	source, ok := managedDeviceSystemImageSources[tag]
+	if !ok {
+		return managedDevice{}, fmt.Errorf("the %s system image tag has no Gradle Managed Devices system image source", tag)
+	}
+	profile := result.Profile
+	if name, ok := profileNames[profile]; ok {
+		profile = name
+	}
+	return managedDevice{
+		Name:              managedDeviceName(result.AVDName),
+		Device:            profile,
+		APILevel:          result.APILevel,
+		SystemImageSource: source,
+		ABI:               result.ABI,
+		Serial:            result.Serial,
+	}, nil
+}
+
+// managedDevicesSnippet returns the Kotlin DSL snippet defining the devices, to be placed into the android block.
+func managedDevicesSnippet(devices []managedDevice) string {
+	var snippet strings.Builder
+	snippet.WriteString("// Generated by the AVD Manager step, place it into the android block of the module's build.gradle.kts\n")
+	snippet.WriteString("// and import com.android.build.api.dsl.ManagedVirtualDevice.\n")
+	snippet.WriteString("testOptions {\n    managedDevices {\n        devices {\n")
+	for _, device := range devices {
+		fmt.Fprintf(&snippet, "            maybeCreate<ManagedVirtualDevice>(%q).apply {\n", device.Name)
+		fmt.Fprintf(&snippet, "                device = %q\n", device.Device)
+		fmt.Fprintf(&snippet, "                apiLevel = %d\n", device.APILevel)
+		fmt.Fprintf(&snippet, "                systemImageSource = %q\n", device.SystemImageSource)
+		snippet.WriteString("            }\n")
+	}
+	snippet.WriteString("        }\n    }\n}\n")
+	return snippet.String()
+}
+
+// writeManagedDevices writes the JSON and the Kotlin DSL definitions of the devices into the deploy directory
+// and returns their paths.
+func writeManagedDevices(deployDir string, devices []managedDevice) (string, string, error) {
+	content, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	jsonPth := filepath.Join(deployDir, managedDevicesJSONFileName)
+	if err := ioutil.WriteFile(jsonPth, content, 0644); err != nil {
+		return "", "", err
+	}
+	snippetPth := filepath.Join(deployDir, managedDevicesSnippetFileName)
+	if err := ioutil.WriteFile(snippetPth, []byte(managedDevicesSnippet(devices)), 0644); err != nil {
+		return "", "", err
+	}
+	return jsonPth, snippetPth, nil
+}
+
+// exportManagedDevices writes the Gradle Managed Devices definitions of the booted devices into the deploy directory
+// and exports their paths as step outputs. The devices which can't be defined are left out with a warning.
+func exportManagedDevices(deployDir, avdManagerPath string, avds []avdSpec, results []bootResult) {
+	fmt.Println()
+	log.Infof("Generating Gradle Managed Devices definitions")
+	profileNames, err := deviceProfileNames(avdManagerPath)
+	if err != nil {
+		log.Warnf("Failed to list device profile names, using the profile IDs: %s", err)
+	}
+	var devices []managedDevice
+	for i, avd := range avds {
+		device, err := newManagedDevice(results[i], avd.tag, profileNames)
+		if err != nil {
+			log.Warnf("%s is left out: %s", avd.name, err)
+			continue
+		}
+		devices = append(devices, device)
+	}
+	if len(devices) > 0 {
+		jsonPth, snippetPth, err := writeManagedDevices(deployDir, devices)
+		if err != nil {
+			log.Warnf("Failed to write Gradle Managed Devices definitions: %s", err)
+		} else {
+			log.Printf("- Definitions saved to: %s", jsonPth)
+			log.Printf("- Gradle snippet saved to: %s", snippetPth)
+			for key, value := range map[string]string{"BITRISE_EMULATOR_MANAGED_DEVICES_PATH": jsonPth, "BITRISE_EMULATOR_MANAGED_DEVICES_SNIPPET_PATH": snippetPth} {
+				if err := tools.ExportEnvironmentWithEnvman(key, value); err != nil {
+					log.Warnf("Failed to export environment (%s), error: %s", key, err)
+				}
+			}
+		}
+	}
+}
@@ -8,4 +8,6 @@ require (
 	github.com/bitrise-io/go-utils v1.0.2
 	github.com/bitrise-io/go-utils/v2 v2.0.0-alpha.8
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emugrpc"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// grpcPortOffset maps the console port of an emulator to its gRPC port, the default console port 5554 gets the default gRPC port 8554.
+const grpcPortOffset = 3000
+
+// grpcFlags returns the flags exposing the gRPC endpoint of the emulator with the given console port (0 for the default one).
+// The endpoint requires the token of the emulator's discovery file.
+func grpcFlags(consolePort int) []string {
+	if consolePort == 0 {
+		consolePort = emulatormanager.FirstEmulatorPort
+	}
+	return []string{"-grpc", strconv.Itoa(consolePort + grpcPortOffset), "-grpc-use-token"}
+}
+
+// dialGRPC connects to the gRPC endpoint of the emulator process.
+func dialGRPC(ctx context.Context, pid int) (*emugrpc.Client, int, error) {
+	port, token, err := emugrpc.Endpoint(pid)
+	if err != nil {
+		return nil, 0, err
+	}
+	client, err := emugrpc.Dial(ctx, port, token)
+	return client, port, err
+}
+
+// saveSnapshotGRPC saves the snapshot through the gRPC endpoint of the emulator process.
+func saveSnapshotGRPC(ctx context.Context, pid int, name string) error {
+	client, _, err := dialGRPC(ctx, pid)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Warnf("Failed to close emulator gRPC connection: %s", err)
+		}
+	}()
+	return client.SaveSnapshot(ctx, name)
+}
+
+// collectScreenshotGRPC saves the current screen of the emulator process as a PNG into dir, it returns whether it succeeded.
+func collectScreenshotGRPC(pid int, serial, dir string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), emugrpc.DefaultTimeout)
+	defer cancel()
+
+	client, _, err := dialGRPC(ctx, pid)
+	if err != nil {
+		log.Warnf("Failed to connect to the gRPC endpoint of %s: %s", serial, err)
+		return false
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Warnf("Failed to close emulator gRPC connection: %s", err)
+		}
+	}()
+
+	log.Printf("Taking screenshot of %s through gRPC", serial)
+	png, err := client.Screenshot(ctx)
+	if err != nil {
+		log.Warnf("Failed to take screenshot: %s", err)
+		return false
+	}
+	pth := artifactPath(dir, "screenshot", serial, "png")
+	if err := ioutil.WriteFile(pth, png, 0644); err != nil {
+		log.Warnf("Failed to write screenshot file: %s", err)
+		return false
+	}
+	log.Printf("- Screenshot saved to: %s", pth)
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "github.com/bitrise-io/go-utils/v2/system"
+
+// hostCPUIsARM reports whether the host has an ARM CPU, even if the step runs translated (Rosetta).
+func hostCPUIsARM() (bool, error) {
+	return system.CPU.IsARM()
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "runtime"
+
+// hostCPUIsARM reports whether the host has an ARM CPU.
+func hostCPUIsARM() (bool, error) {
+	return runtime.GOARCH == "arm64", nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/bitrise-io/go-utils/log"
+
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// bootInputs are the inputs of the boot mode parsed by the startup validation.
+type bootInputs struct {
+	matrix       []matrixEntry
+	apkPaths     []string
+	filePushes   []filePush
+	a11yServices []string
+}
+
+// validateInputs fails the step on an invalid input or input combination before anything is downloaded or booted.
+// The options which conflict with Quick Boot or the AVD cache are turned off.
+func validateInputs(cfg *config) bootInputs {
+	if cfg.CacheSnapshot && !cfg.QuickBoot {
+		log.Warnf("Snapshot caching requires Quick Boot, enabling it")
+		cfg.QuickBoot = true
+		fmt.Println()
+	}
+	if cfg.QuickBoot {
+		// Quick Boot loads the snapshot of the persisted device state
+		cfg.ColdBoot, cfg.WipeData = false, false
+	}
+	// a restored AVD would throw away the cached data and snapshots with -wipe-data and -no-snapshot
+	if cfg.AVDCacheDir != "" && (cfg.WipeData || cfg.ColdBoot) {
+		log.Warnf("The AVD cache keeps the data and the snapshots of the AVD, disabling data wiping and cold boot")
+		cfg.ColdBoot, cfg.WipeData = false, false
+		fmt.Println()
+	}
+	// the screen recording, the health monitor and the system modification script run in a shell,
+	// the resource metrics rely on ps and the certificate hash is computed by openssl
+	if runtime.GOOS == "windows" {
+		if cfg.RecordScreen {
+			failf("Issue with input: record_screen: not supported on Windows")
+		}
+		if cfg.MonitorHealth {
+			failf("Issue with input: monitor_health: not supported on Windows")
+		}
+		if cfg.ResourceMetrics != "no" {
+			failf("Issue with input: resource_metrics: not supported on Windows")
+		}
+		if cfg.SystemScript != "" {
+			failf("Issue with input: system_modification_script: not supported on Windows")
+		}
+		if cfg.CACertificate != "" {
+			failf("Issue with input: ca_certificate: not supported on Windows")
+		}
+	}
+
+	if cfg.ReuseRunning && cfg.CleanStart {
+		failf("Issue with input: reuse_running_emulator: can't be combined with clean_start, which stops the running emulators")
+	}
+	if cfg.EmulatorVersion != "" && !emulatorVersionInputPattern.MatchString(cfg.EmulatorVersion) {
+		failf("Issue with input: emulator_version: invalid version (%s), use a version like 33.1 or 33.1.24", cfg.EmulatorVersion)
+	}
+
+	var inputs bootInputs
+	var err error
+	// the matrix replaces the API level and profile inputs
+	inputs.matrix = []matrixEntry{{apiLevel: cfg.APILevel, profile: cfg.DeviceProfile}}
+	if cfg.DeviceMatrix != "" {
+		inputs.matrix, err = parseDeviceMatrix(cfg.DeviceMatrix)
+		if err != nil {
+			failf("Issue with input: device_matrix: %s", err)
+		}
+		if len(inputs.matrix) == 0 {
+			failf("Issue with input: device_matrix: no entry listed")
+		}
+	}
+
+	if cfg.HTTPProxy != "" {
+		if _, err := proxyHostPort(cfg.HTTPProxy); err != nil {
+			failf("Issue with input: http_proxy: %s", err)
+		}
+	}
+
+	inputs.apkPaths, err = resolveAPKPaths(cfg.InstallAPKs)
+	if err != nil {
+		failf("Issue with input: install_apks: %s", err)
+	}
+
+	inputs.filePushes, err = parseFilePushes(cfg.PushFiles)
+	if err != nil {
+		failf("Issue with input: push_files: %s", err)
+	}
+
+	if cfg.CACertificate != "" {
+		if _, err := caCertificateName(cfg.CACertificate); err != nil {
+			failf("Issue with input: ca_certificate: %s", err)
+		}
+		for _, entry := range inputs.matrix {
+			if err := validateCACertificateAPILevel(entry.apiLevel); err != nil {
+				failf("Issue with input: ca_certificate: %s", err)
+			}
+		}
+	}
+	if emulatormanager.IsPlayStoreImage(cfg.Tag) {
+		// fail before the long system image download instead of after the boot
+		if cfg.RootAccess {
+			failf("Issue with input: root_access: %s images don't allow root access, use the google_apis tag instead", cfg.Tag)
+		}
+		if cfg.WritableSystem {
+			failf("Issue with input: writable_system: the system partition of %s images can't be modified, use the google_apis tag instead", cfg.Tag)
+		}
+		if cfg.CACertificate != "" {
+			failf("Issue with input: ca_certificate: the system certificate store of %s images can't be modified, use the google_apis tag instead", cfg.Tag)
+		}
+	}
+	if cfg.SystemScript != "" && !cfg.WritableSystem {
+		failf("Issue with input: system_modification_script: requires writable_system to be enabled")
+	}
+
+	if cfg.GPSLocation != "" {
+		if _, err := parseGPSLocation(cfg.GPSLocation); err != nil {
+			failf("Issue with input: gps_location: %s", err)
+		}
+	}
+	inputs.a11yServices, err = parseAccessibilityServices(cfg.A11yServices)
+	if err != nil {
+		failf("Issue with input: accessibility_services: %s", err)
+	}
+	if _, err := parseFontScale(cfg.FontScale); err != nil {
+		failf("Issue with input: font_scale: %s", err)
+	}
+	if _, err := parseBatteryLevel(cfg.BatteryLevel); err != nil {
+		failf("Issue with input: battery_level: %s", err)
+	}
+	if _, err := parseTelephonyEvents(cfg.TelephonyEvents); err != nil {
+		failf("Issue with input: telephony_events: %s", err)
+	}
+
+	return inputs
+}
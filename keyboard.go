@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// disableSoftKeyboard keeps the on-screen keyboard, the spell checker and autofill from popping up over the UI under test:
+// every input method is disabled, so no IME is shown when a text field gets the focus.
+func disableSoftKeyboard(adbClient emulatormanager.ADB, serial string) error {
+	out, err := adbClient.Shell(serial, "ime", "list", "-s")
+	if err != nil {
+		return err
+	}
+	for _, ime := range strings.Fields(out) {
+		if _, err := adbClient.Shell(serial, "ime", "disable", ime); err != nil {
+			return err
+		}
+	}
+
+	for _, setting := range [][]string{
+		{"show_ime_with_hard_keyboard", "0"},
+		{"spell_checker_enabled", "0"},
+		{"autofill_service", "null"},
+	} {
+		if _, err := adbClient.Shell(serial, "settings", "put", "secure", setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const defaultLicenseName = "android-sdk-license"
+
+// licenseHashPattern matches an SDK license hash, the content of a line of a license file in the licenses directory.
+var licenseHashPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// yesReader answers every sdkmanager prompt with y, unlike a fixed number of answers it can't run out,
+// so sdkmanager never hangs waiting for input.
+type yesReader struct{}
+
+func (yesReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = "y\n"[i%2]
+	}
+	// only whole answers
+	return len(p) - len(p)%2, nil
+}
+
+// parseLicenseHashes parses the newline separated <license name>:<hash> list, a hash without a name belongs to the android-sdk-license.
+func parseLicenseHashes(list string) (map[string][]string, error) {
+	hashes := map[string][]string{}
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, hash := defaultLicenseName, line
+		if separator := strings.Index(line, ":"); separator >= 0 {
+			name, hash = strings.TrimSpace(line[:separator]), strings.TrimSpace(line[separator+1:])
+		}
+		if !licenseHashPattern.MatchString(hash) || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid license (%s), expected format: [<license name>:]<40 character hash>", line)
+		}
+		hashes[name] = append(hashes[name], hash)
+	}
+	return hashes, nil
+}
+
+// writeLicenseHashes adds the hashes to the license files of the SDK, keeping the already accepted ones.
+func writeLicenseHashes(androidHome string, hashes map[string][]string) error {
+	dir := filepath.Join(androidHome, "licenses")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, licenseHashes := range hashes {
+		pth := filepath.Join(dir, name)
+		var content string
+		if exists, err := pathutil.IsPathExists(pth); err != nil {
+			return err
+		} else if exists {
+			bytes, err := ioutil.ReadFile(pth)
+			if err != nil {
+				return err
+			}
+			content = string(bytes)
+		}
+
+		for _, hash := range licenseHashes {
+			if strings.Contains(content, hash) {
+				continue
+			}
+			if content != "" && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			content += hash + "\n"
+		}
+		if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acceptLicensesCommand returns the sdkmanager command accepting every SDK license, it is killed once ctx is done.
+func acceptLicensesCommand(ctx context.Context, sdkManagerPath string) *command.Model {
+	return command.NewWithCmd(exec.CommandContext(ctx, sdkManagerPath, "--licenses")).SetStdin(yesReader{})
+}
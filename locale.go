@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// localePattern matches the BCP 47 language tags accepted by the -change-locale flag, like en, de-DE or zh-Hans-CN.
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// localeFlags returns the emulator flags which set the locale and the timezone of the device.
+func localeFlags(locale, timezone string) ([]string, error) {
+	var flags []string
+	if locale != "" {
+		if !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("invalid locale (%s), expected a language tag like en-US", locale)
+		}
+		flags = append(flags, "-change-locale", locale)
+	}
+	if timezone != "" {
+		flags = append(flags, "-timezone", timezone)
+	}
+	return flags, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// gpsLocation is a latitude, longitude pair in decimal degrees.
+type gpsLocation struct {
+	latitude, longitude float64
+}
+
+// parseGPSLocation parses the <latitude>,<longitude> input.
+func parseGPSLocation(s string) (gpsLocation, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return gpsLocation{}, fmt.Errorf("invalid location (%s), expected format: <latitude>,<longitude>", s)
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return gpsLocation{}, fmt.Errorf("invalid latitude (%s), expected a number between -90 and 90", parts[0])
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return gpsLocation{}, fmt.Errorf("invalid longitude (%s), expected a number between -180 and 180", parts[1])
+	}
+	return gpsLocation{latitude: latitude, longitude: longitude}, nil
+}
+
+// setGPSLocation returns an action which sets the location through the emulator console.
+// The location is kept by the emulator process, so it survives device reboots, but a restarted emulator process
+// (for example a retried boot attempt) starts without it; that's why it is applied after every completed boot attempt.
+func setGPSLocation(location gpsLocation) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(_ emulatormanager.ADB, serial string) error {
+		client, err := emuconsole.DialSerial(serial)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				log.Warnf("Failed to close emulator console connection: %s", err)
+			}
+		}()
+		return client.GeoFix(location.latitude, location.longitude)
+	}
+}
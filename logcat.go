@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// logcatPath returns where the logcat of the device is written in the deploy directory.
+func logcatPath(deployDir, serial string, multipleDevices bool) string {
+	if multipleDevices {
+		return filepath.Join(deployDir, fmt.Sprintf("emulator-logcat-%s.txt", serial))
+	}
+	return filepath.Join(deployDir, "emulator-logcat.txt")
+}
+
+// startLogcat streams the logcat of the device into the file at path and returns the pid of the adb process.
+// The process is not waited for, so it keeps running after the step finishes, for the lifetime of the build.
+func startLogcat(adbClient emulatormanager.ADB, serial, path string) (int, error) {
+	logFile, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	// the child process gets its own copy of the file descriptor
+	defer func() {
+		if err := logFile.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", path, err)
+		}
+	}()
+
+	cmd := command.New(adbClient.Path, "-s", serial, "logcat", "-v", "threadtime").SetStdout(logFile).SetStderr(logFile)
+	// the terminate mode stops the whole process group
+	emulatormanager.StartInProcessGroup(cmd.GetCmd())
+	if err := cmd.GetCmd().Start(); err != nil {
+		return 0, fmt.Errorf("failed to start %s: %s", cmd.PrintableCommandArgs(), err)
+	}
+
+	pid := cmd.GetCmd().Process.Pid
+	if err := cmd.GetCmd().Process.Release(); err != nil {
+		return pid, fmt.Errorf("failed to release logcat process: %s", err)
+	}
+	return pid, nil
+}
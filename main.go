@@ -1,13 +1,17 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/bitrise-io/go-android/sdk"
@@ -15,119 +19,134 @@ import (
 	"github.com/bitrise-io/go-steputils/tools"
 	"github.com/bitrise-io/go-utils/command"
 	"github.com/bitrise-io/go-utils/log"
-	"github.com/bitrise-io/go-utils/v2/system"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
 	"github.com/kballard/go-shellquote"
 )
 
 // config ...
 type config struct {
+	Command           string `env:"command,opt[boot,terminate]"`
 	AndroidHome       string `env:"ANDROID_HOME"`
 	AndroidSDKRoot    string `env:"ANDROID_SDK_ROOT"`
+	DeployDir         string `env:"BITRISE_DEPLOY_DIR"`
 	APILevel          int    `env:"api_level,required"`
-	Tag               string `env:"tag,opt[google_apis,google_apis_playstore,aosp_atd,google_atd,android-wear,android-tv,default]"`
+	Tag               string `env:"tag,opt[google_apis,google_apis_playstore,aosp_atd,google_atd,android-wear,android-tv,google-tv,android-automotive,android-automotive-playstore,default]"`
 	DeviceProfile     string `env:"profile,required"`
+	DeviceMatrix      string `env:"device_matrix"`
 	CreateCommandArgs string `env:"create_command_flags"`
 	StartCommandArgs  string `env:"start_command_flags"`
+	DisabledFlags     string `env:"disabled_start_command_flags"`
+	Features          string `env:"features"`
 	ID                string `env:"emulator_id,required"`
-	Abi               string `env:"abi,opt[x86,armeabi-v7a,arm64-v8a,x86_64]"`
+	Abi               string `env:"abi,opt[auto,x86,armeabi-v7a,arm64-v8a,x86_64]"`
+	ABIs              string `env:"abis"`
 	EmulatorChannel   string `env:"emulator_channel,opt[0,1,2,3]"`
-}
-
-var (
-	faultIndicators = []string{" BUG: ", "Kernel panic"}
-)
-
-const (
-	bootTimeout         = time.Duration(10) * time.Minute
-	deviceCheckInterval = time.Duration(5) * time.Second
-	maxBootAttempts     = 5
-)
-
-func runningDeviceInfos(androidHome string) (map[string]string, error) {
-	cmd := command.New(filepath.Join(androidHome, "platform-tools", "adb"), "devices")
-	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
-	if err != nil {
-		log.Printf(err.Error())
-		return map[string]string{}, fmt.Errorf("command failed, error: %s", err)
-	}
-
-	log.Debugf("$ %s", cmd.PrintableCommandArgs())
-	log.Debugf("%s", out)
-
-	// List of devices attached
-	// emulator-5554	device
-	deviceListItemPattern := `^(?P<emulator>emulator-\d*)[\s+](?P<state>.*)`
-	deviceListItemRegexp := regexp.MustCompile(deviceListItemPattern)
-
-	deviceStateMap := map[string]string{}
-
-	scanner := bufio.NewScanner(strings.NewReader(out))
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := deviceListItemRegexp.FindStringSubmatch(line)
-		if len(matches) == 3 {
-			serial := matches[1]
-			state := matches[2]
-
-			deviceStateMap[serial] = state
-		}
-
-	}
-	if scanner.Err() != nil {
-		return map[string]string{}, fmt.Errorf("scanner failed, error: %s", err)
-	}
-
-	return deviceStateMap, nil
+	MaxBootAttempts   int    `env:"max_boot_attempts,range[1..100]"`
+	Headless          bool   `env:"headless_mode,opt[yes,no]"`
+	GPUMode           string `env:"gpu_mode,opt[auto,host,swiftshader_indirect,angle_indirect,guest]"`
+	QuickBoot         bool   `env:"quick_boot,opt[yes,no]"`
+	ColdBoot          bool   `env:"cold_boot,opt[yes,no]"`
+	WipeData          bool   `env:"wipe_data,opt[yes,no]"`
+	MonitorHealth     bool   `env:"monitor_health,opt[yes,no]"`
+	ResourceMetrics   string `env:"resource_metrics,opt[no,summary,csv]"`
+	StuckBootTimeout  int    `env:"stuck_boot_timeout,range[0..86400]"`
+	PollInterval      int    `env:"device_poll_interval,range[1..600]"`
+	ADBRestartPolls   int    `env:"adb_restart_threshold,range[0..10000]"`
+	DryRun            bool   `env:"dry_run,opt[yes,no]"`
+	LogVerbosity      string `env:"log_verbosity,opt[quiet,normal,debug]"`
+	JSONEvents        bool   `env:"json_events,opt[yes,no]"`
+	StatusFile        string `env:"status_file"`
+	EmulatorUpdate    string `env:"emulator_update,opt[update,install_missing,no]"`
+	EmulatorVersion   string `env:"emulator_version"`
+	AVDHome           string `env:"avd_home"`
+	DisableMetrics    bool   `env:"disable_metrics,opt[yes,no]"`
+	GRPCControl       bool   `env:"grpc_control,opt[yes,no]"`
+	ManagedDevices    bool   `env:"gradle_managed_devices,opt[yes,no]"`
+	SnapshotName      string `env:"snapshot_name"`
+	CacheSnapshot     bool   `env:"cache_snapshot,opt[yes,no]"`
+	AVDCacheDir       string `env:"avd_cache_dir"`
+	EmulatorCount     int    `env:"emulator_count,range[1..32]"`
+	WaitBootComplete  bool   `env:"wait_for_boot_complete,opt[yes,no]"`
+	DisableAnimations bool   `env:"disable_animations,opt[yes,no]"`
+	UnlockScreen      bool   `env:"unlock_screen,opt[yes,no]"`
+	NoSoftKeyboard    bool   `env:"disable_soft_keyboard,opt[yes,no]"`
+	NoPackageVerifier bool   `env:"disable_package_verification,opt[yes,no]"`
+	NightMode         string `env:"night_mode,opt[default,yes,no]"`
+	FontScale         string `env:"font_scale"`
+	DisplayDensity    int    `env:"display_density,range[0..1000]"`
+	CaptureLogcat     bool   `env:"capture_logcat,opt[yes,no]"`
+	DeviceReport      bool   `env:"device_report,opt[yes,no]"`
+	SaveEmulatorLog   bool   `env:"save_emulator_log,opt[yes,no]"`
+	BugreportOnError  bool   `env:"bugreport_on_failure,opt[yes,no]"`
+	ScreenshotOnError bool   `env:"screenshot_on_failure,opt[yes,no]"`
+	CrashDumpsOnError bool   `env:"crash_dumps_on_failure,opt[yes,no]"`
+	RecordScreen      bool   `env:"record_screen,opt[yes,no]"`
+	CheckAcceleration bool   `env:"check_acceleration,opt[yes,no]"`
+	AccelFallback     string `env:"acceleration_fallback,opt[fail,software]"`
+	CheckDiskSpace    bool   `env:"check_disk_space,opt[yes,no]"`
+	ValidateEnv       bool   `env:"validate_environment,opt[yes,no]"`
+	LicenseHashes     string `env:"sdk_license_hashes"`
+	DetectionTimeout  int    `env:"device_detection_timeout,range[1..86400]"`
+	BootTimeout       int    `env:"boot_complete_timeout,range[1..86400]"`
+	PostBootTimeout   int    `env:"post_boot_timeout,range[1..86400]"`
+	OverallTimeout    int    `env:"overall_timeout,range[0..86400]"`
+	CleanStart        bool   `env:"clean_start,opt[yes,no]"`
+	ReuseRunning      bool   `env:"reuse_running_emulator,opt[yes,no]"`
+	Port              string `env:"port"`
+	HTTPProxy         string `env:"http_proxy"`
+	NetworkDelay      string `env:"network_delay,opt[none,gsm,gprs,edge,umts,hsdpa,lte,evdo]"`
+	NetworkSpeed      string `env:"network_speed,opt[full,gsm,hscsd,gprs,edge,umts,hsdpa,lte,evdo]"`
+	InstallAPKs       string `env:"install_apks"`
+	PushFiles         string `env:"push_files"`
+	GrantPermissions  string `env:"grant_permissions"`
+	A11yServices      string `env:"accessibility_services"`
+	CACertificate     string `env:"ca_certificate"`
+	Locale            string `env:"locale"`
+	Timezone          string `env:"timezone"`
+	GPSLocation       string `env:"gps_location"`
+	BatteryLevel      string `env:"battery_level"`
+	BatteryCharging   string `env:"battery_charging,opt[default,charging,discharging,not-charging,full]"`
+	BatteryHealth     string `env:"battery_health,opt[default,good,overheat,dead,overvoltage,failure,unknown]"`
+	TelephonyEvents   string `env:"telephony_events"`
+	FingerprintID     int    `env:"fingerprint_enrollment,range[0..10]"`
+	FoldPosture       string `env:"fold_posture,opt[default,closed,half-open,opened,flipped,tent]"`
+	RootAccess        bool   `env:"root_access,opt[yes,no]"`
+	WritableSystem    bool   `env:"writable_system,opt[yes,no]"`
+	SystemScript      string `env:"system_modification_script"`
+	RAMSize           int    `env:"ram_size"`
+	VMHeapSize        int    `env:"vm_heap_size"`
+	ScreenResolution  string `env:"screen_resolution"`
+	ScreenDensity     int    `env:"screen_density"`
+	SDCard            string `env:"sdcard"`
+	Kernel            string `env:"kernel"`
+	Ramdisk           string `env:"ramdisk"`
+	DataPartitionSize int    `env:"data_partition_size,range[512..65536]"`
+	FormFactor        string `env:"form_factor,opt[auto,phone,wear,tv,automotive]"`
 }
 
 func failf(msg string, args ...interface{}) {
 	log.Errorf(msg, args...)
 
-	cpuIsARM, err := system.CPU.IsARM()
+	if forensics != nil && forensics.fired() {
+		forensics.collect()
+	}
+	status.finish(statusFailed, fmt.Sprintf(msg, args...))
+
+	cpuIsARM, err := hostCPUIsARM()
 	if err != nil {
 		log.Errorf("Failed to check CPU: %s", err)
 	} else if cpuIsARM {
-		log.Warnf("This Step is not yet supported on Apple Silicon (M1) machines. If you cannot find a solution to this error, try running this Workflow on an Intel-based machine type.")
+		log.Warnf("On Apple Silicon (M1) machines only arm64-v8a system images are supported. If you cannot find a solution to this error, try running this Workflow on an Intel-based machine type.")
 	}
 
 	os.Exit(1)
 }
 
-func currentlyStartedDeviceSerial(alreadyRunningDeviceInfos, currentlyRunningDeviceInfos map[string]string) string {
-	startedSerial := ""
-
-	for serial := range currentlyRunningDeviceInfos {
-		_, found := alreadyRunningDeviceInfos[serial]
-		if !found {
-			startedSerial = serial
-			break
-		}
-	}
-
-	if len(startedSerial) > 0 {
-		state := currentlyRunningDeviceInfos[startedSerial]
-		if state == "device" {
-			return startedSerial
-		}
-	}
-
-	return ""
-}
-
-func queryNewDeviceSerial(androidHome string, runningDevices map[string]string) (string, error) {
-	currentRunningDevices, err := runningDeviceInfos(androidHome)
-	if err != nil {
-		return "", fmt.Errorf("failed to check running devices: %s", err)
-	}
-
-	serial := currentlyStartedDeviceSerial(runningDevices, currentRunningDevices)
-
-	return serial, nil
-}
-
 type phase struct {
 	name    string
 	command *command.Model
+	// streamed phases print their output while running instead of returning it
+	streamed bool
 }
 
 func main() {
@@ -137,6 +156,19 @@ func main() {
 	}
 	stepconf.Print(cfg)
 	fmt.Println()
+	log.SetEnableDebugLog(cfg.LogVerbosity == "debug")
+
+	if cfg.AVDHome != "" {
+		if err := useAVDHome(cfg.AVDHome); err != nil {
+			failf("Issue with input: avd_home: %s", err)
+		}
+		log.Printf("Using AVD home: %s", os.Getenv("ANDROID_AVD_HOME"))
+		// later steps (like the adb or the cache steps) have to find the AVDs too
+		if err := tools.ExportEnvironmentWithEnvman("ANDROID_AVD_HOME", os.Getenv("ANDROID_AVD_HOME")); err != nil {
+			log.Warnf("Failed to export environment (ANDROID_AVD_HOME), error: %s", err)
+		}
+		fmt.Println()
+	}
 
 	// Initialize Android SDK
 	log.Printf("Initialize Android SDK")
@@ -148,26 +180,125 @@ func main() {
 		failf("Failed to initialize Android SDK: %s", err)
 	}
 
+	formFactor, err := emulatormanager.ParseFormFactor(cfg.FormFactor, cfg.Tag)
+	if err != nil {
+		failf("Issue with input: form_factor: %s", err)
+	}
+	if formFactor != emulatormanager.FormFactorPhone {
+		log.Printf("Using %s form factor", formFactor)
+	}
+
+	hostIsARM, err := hostCPUIsARM()
+	if err != nil {
+		failf("Failed to check CPU architecture: %s", err)
+	}
+	abiList := cfg.Abi
+	if cfg.ABIs != "" {
+		abiList = cfg.ABIs
+	}
+	abis, err := resolveABIs(abiList, hostIsARM)
+	if err != nil {
+		failf("Incompatible system image: %s", err)
+	}
+	if len(abis) == 0 {
+		failf("Issue with input: abis: no ABI listed")
+	}
+	if strings.Join(abis, ",") != abiList {
+		log.Printf("Using %s system image ABI(s)", strings.Join(abis, ", "))
+	}
+	cfg.Abi = abis[0]
+
 	androidHome := androidSdk.GetAndroidHome()
-	runningDevices, err := runningDeviceInfos(androidHome)
+	if cfg.Command == "terminate" {
+		log.Infof("Terminating emulators")
+		if err := terminateSession(emulatormanager.NewADB(androidHome), sessionFilePath()); err != nil {
+			failf("Failed to terminate emulators:\n%s", err)
+		}
+		log.Donef("- Done")
+		return
+	}
+	inputs := validateInputs(&cfg)
+
+	// an aborted build interrupts the step: the ongoing adb commands are killed and the booting emulators are shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// a second signal terminates the step right away
+		stop()
+	}()
+	// the overall timeout aborts the ongoing adb commands and boots the same way
+	if cfg.OverallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.OverallTimeout)*time.Second)
+		defer cancel()
+		// armed before the SDK phases, the AVDs are added once they are known
+		forensics = &timeoutForensics{ctx: ctx, adbClient: emulatormanager.NewADB(androidHome), deployDir: cfg.DeployDir}
+	}
+
+	// the filtered log verbosities drop lines from the build log, so the complete output is persisted
+	emulatorLogPath := func(avd avdSpec) string {
+		if cfg.SaveEmulatorLog || cfg.LogVerbosity != "debug" {
+			return filepath.Join(cfg.DeployDir, fmt.Sprintf("emulator-%s.log", avd.name))
+		}
+		return ""
+	}
+
+	if cfg.ValidateEnv {
+		log.Infof("Validating environment")
+		// a missing cmdline-tools package is reported by its check
+		toolsPath, _ := androidSdk.CmdlineToolsPath()
+		accelerationRequired := cfg.CheckAcceleration && !cfg.DryRun && requiresAcceleration(cfg.Abi, hostIsARM)
+		if err := validateEnvironment(environmentChecks(androidHome, toolsPath, cfg.EmulatorUpdate == "no", accelerationRequired)); err != nil {
+			failf("Environment validation failed: %s", err)
+		}
+		fmt.Println()
+	}
+
+	adbClient := emulatormanager.NewADB(androidHome).WithContext(ctx)
+	runningDevices, err := adbClient.Devices()
 	if err != nil {
 		failf("Failed to check running devices, error: %s", err)
 	}
 
+	if cfg.CleanStart && cfg.DryRun {
+		log.Infof("Cleaning up leftover emulators")
+		log.Printf("Dry run: skipping the cleanup of %d running emulator(s)", len(runningDevices))
+		fmt.Println()
+	} else if cfg.CleanStart {
+		log.Infof("Cleaning up leftover emulators")
+		if err := cleanupEmulators(adbClient, runningDevices); err != nil {
+			failf("Failed to clean up leftover emulators: %s", err)
+		}
+		runningDevices, err = adbClient.Devices()
+		if err != nil {
+			failf("Failed to check running devices, error: %s", err)
+		}
+		fmt.Println()
+	}
+
 	cmdlineToolsPath, err := androidSdk.CmdlineToolsPath()
 	if err != nil {
 		failf("Could not locate Android command-line tools: %v", err)
 	}
 
 	var (
-		sdkManagerPath = filepath.Join(cmdlineToolsPath, "sdkmanager")
-		avdManagerPath = filepath.Join(cmdlineToolsPath, "avdmanager")
-		emulatorPath   = filepath.Join(androidHome, "emulator", "emulator")
-
-		pkg     = fmt.Sprintf("system-images;android-%d;%s;%s", cfg.APILevel, cfg.Tag, cfg.Abi)
-		yes, no = strings.Repeat("yes\n", 20), strings.Repeat("no\n", 20)
+		sdkManagerPath = cmdlineTool(cmdlineToolsPath, "sdkmanager")
+		avdManagerPath = cmdlineTool(cmdlineToolsPath, "avdmanager")
+		// the emulator is resolved once the emulator package is updated, this is the default location
+		emulatorPath = filepath.Join(androidHome, "emulator", emulatormanager.Executable("emulator"))
 	)
 
+	if profiles, err := deviceProfiles(avdManagerPath); err != nil {
+		log.Warnf("Failed to list device profiles: %s", err)
+	} else {
+		for _, entry := range inputs.matrix {
+			if err := validateDeviceProfile(entry.profile, profiles); err != nil {
+				failf("Issue with input: profile: %s", err)
+			}
+		}
+	}
+
 	// parse custom flags
 	createCustomFlags, err := shellquote.Split(cfg.CreateCommandArgs)
 	if err != nil {
@@ -177,147 +308,712 @@ func main() {
 	if err != nil {
 		failf("Failed to parse start command args, error: %s", err)
 	}
+	disabledFlags, err := shellquote.Split(cfg.DisabledFlags)
+	if err != nil {
+		failf("Failed to parse disabled start command flags, error: %s", err)
+	}
+	if !cfg.Headless {
+		disabledFlags = append(disabledFlags, "-no-window", "-no-boot-anim")
+	}
+	if !cfg.ColdBoot {
+		disabledFlags = append(disabledFlags, "-no-snapshot")
+	}
+	if !cfg.WipeData {
+		disabledFlags = append(disabledFlags, "-wipe-data")
+	}
+	if cfg.QuickBoot {
+		if cfg.SnapshotName != "" {
+			startCustomFlags = append([]string{"-snapshot", cfg.SnapshotName}, startCustomFlags...)
+		}
+	}
+
+	if cfg.HTTPProxy != "" {
+		startCustomFlags = append([]string{"-http-proxy", cfg.HTTPProxy}, startCustomFlags...)
+	}
 
-	for _, phase := range []phase{
-		{
-			"Updating emulator",
-			command.New(sdkManagerPath, "--verbose", "--channel="+cfg.EmulatorChannel, "emulator").
-				SetStdin(strings.NewReader(yes)), // hitting yes in case it waits for accepting license
-		},
-
-		{
-			"Updating system-image packages",
-			command.New(sdkManagerPath, "--verbose", "--channel="+cfg.EmulatorChannel, pkg).
-				SetStdin(strings.NewReader(yes)), // hitting yes in case it waits for accepting license
-		},
-
-		{
-			"Creating device",
-			command.New(avdManagerPath, append([]string{
-				"--verbose", "create", "avd", "--force",
-				"--name", cfg.ID,
-				"--device", cfg.DeviceProfile,
-				"--package", pkg,
-				"--tag", cfg.Tag,
-				"--abi", cfg.Abi}, createCustomFlags...)...).
-				SetStdin(strings.NewReader(no)), // hitting no in case it asks for creating hw profile
-		},
+	if cfg.CACertificate != "" || cfg.RootAccess || cfg.WritableSystem {
+		startCustomFlags = append([]string{"-writable-system"}, startCustomFlags...)
+	}
+
+	hardware := hardwareOverrides{
+		ramSizeMB:           cfg.RAMSize,
+		vmHeapSizeMB:        cfg.VMHeapSize,
+		density:             cfg.ScreenDensity,
+		dataPartitionSizeMB: cfg.DataPartitionSize,
+	}
+	if cfg.DataPartitionSize > 0 {
+		startCustomFlags = append([]string{"-partition-size", strconv.Itoa(cfg.DataPartitionSize)}, startCustomFlags...)
+	}
+	if cfg.ScreenResolution != "" {
+		hardware.screenWidth, hardware.screenHeight, err = parseScreenResolution(cfg.ScreenResolution)
+		if err != nil {
+			failf("Issue with input: screen_resolution: %s", err)
+		}
+	}
+
+	sdcardSize, sdcardImage, err := sdcardOption(cfg.SDCard)
+	if err != nil {
+		failf("Issue with input: sdcard: %s", err)
+	}
+	if sdcardImage != "" {
+		if count := cfg.EmulatorCount * len(abis) * len(inputs.matrix); count > 1 {
+			failf("Issue with input: sdcard: an SD card image can't be shared by %d emulators", count)
+		}
+		startCustomFlags = append([]string{"-sdcard", sdcardImage}, startCustomFlags...)
+	}
+
+	for _, image := range []struct{ input, flag, path string }{
+		{"kernel", "-kernel", cfg.Kernel},
+		{"ramdisk", "-ramdisk", cfg.Ramdisk},
 	} {
+		flags, err := bootImageFlag(image.flag, image.path)
+		if err != nil {
+			failf("Issue with input: %s: %s", image.input, err)
+		}
+		startCustomFlags = append(flags, startCustomFlags...)
+	}
+
+	featureCustomFlags, err := featureFlags(cfg.Features)
+	if err != nil {
+		failf("Issue with input: features: %s", err)
+	}
+	startCustomFlags = append(featureCustomFlags, startCustomFlags...)
+
+	localeCustomFlags, err := localeFlags(cfg.Locale, cfg.Timezone)
+	if err != nil {
+		failf("Issue with input: %s", err)
+	}
+	startCustomFlags = append(localeCustomFlags, startCustomFlags...)
+
+	startFlags := emulatormanager.WithFlagValues(emulatormanager.DefaultStartFlags, "-netdelay", cfg.NetworkDelay)
+	startFlags = emulatormanager.WithFlagValues(startFlags, "-netspeed", cfg.NetworkSpeed)
+
+	// every matrix entry and ABI gets its own AVD(s), suffixed with the entry and the ABI when multiple ones are booted
+	var avds []avdSpec
+	for _, entry := range inputs.matrix {
+		for _, abi := range abis {
+			id := cfg.ID
+			if cfg.DeviceMatrix != "" {
+				id = fmt.Sprintf("%s_%s", id, entry.name())
+			}
+			if len(abis) > 1 {
+				id = fmt.Sprintf("%s_%s", id, abi)
+			}
+			for i := 0; i < cfg.EmulatorCount; i++ {
+				name := id
+				if i > 0 {
+					name = fmt.Sprintf("%s_%d", id, i+1)
+				}
+				avds = append(avds, avdSpec{
+					name:       name,
+					apiLevel:   entry.apiLevel,
+					tag:        cfg.Tag,
+					abi:        abi,
+					profile:    entry.profile,
+					sdcardSize: sdcardSize,
+					createArgs: createCustomFlags,
+				})
+			}
+		}
+	}
+
+	if forensics != nil {
+		for _, avd := range avds {
+			forensics.avdNames = append(forensics.avdNames, avd.name)
+			forensics.logPaths = append(forensics.logPaths, emulatorLogPath(avd))
+		}
+	}
+
+	// the running emulators of the requested AVDs are reused instead of booting new ones
+	reusedSerials := map[string]string{}
+	if cfg.ReuseRunning {
+		log.Infof("Looking for running emulators to reuse")
+		running := runningAVDs(adbClient, runningDevices)
+		for _, avd := range avds {
+			serial, ok := running[avd.name]
+			if !ok {
+				log.Printf("- No running emulator of %s, booting it", avd.name)
+				continue
+			}
+			if mismatches, err := existingAVDMismatches(avd); err != nil {
+				log.Warnf("Failed to verify the AVD (%s) of the running emulator: %s", avd.name, err)
+			} else if len(mismatches) > 0 {
+				failf("The running emulator (%s) of %s doesn't match the inputs, stop it or enable the clean start:\n- %s", serial, avd.name, strings.Join(mismatches, "\n- "))
+			}
+			log.Printf("- Reusing the running emulator (%s) of %s", serial, avd.name)
+			reusedSerials[avd.name] = serial
+		}
+		fmt.Println()
+	}
+
+	updateEmulator := cfg.EmulatorUpdate == "update"
+	if cfg.EmulatorUpdate == "install_missing" {
+		if _, err := emulatormanager.FindEmulator(androidHome, cfg.AndroidSDKRoot, cfg.AndroidHome); err != nil {
+			log.Printf("Installing the emulator package: %s", err)
+			fmt.Println()
+			updateEmulator = true
+		}
+	}
+
+	// updating a pinned emulator would replace it with the latest one of the channel
+	if cfg.EmulatorVersion != "" && updateEmulator {
+		if installedPath, err := emulatormanager.FindEmulator(androidHome, cfg.AndroidSDKRoot, cfg.AndroidHome); err == nil {
+			if version, err := emulatorVersion(installedPath); err == nil && emulatorVersionMatches(version, cfg.EmulatorVersion) {
+				log.Printf("Emulator %s is already installed, skipping the emulator update", version)
+				fmt.Println()
+				updateEmulator = false
+			}
+		}
+	}
+
+	if updateEmulator && len(reusedSerials) == len(avds) {
+		log.Printf("Every device is reused, skipping the emulator update")
+		fmt.Println()
+		updateEmulator = false
+	}
+
+	var phases []phase
+	if updateEmulator {
+		phases = append(phases, phase{
+			name: "Updating emulator",
+			command: command.NewWithCmd(exec.CommandContext(ctx, sdkManagerPath, "--verbose", "--channel="+cfg.EmulatorChannel, "emulator")).
+				SetStdin(yesReader{}), // hitting yes in case it waits for accepting license
+		})
+	}
+
+	// the clones of an ABI share its system image
+	systemImages := map[string]bool{}
+	missingSystemImages := 0
+	for _, avd := range avds {
+		if systemImages[avd.systemImagePackage()] || reusedSerials[avd.name] != "" {
+			continue
+		}
+		systemImages[avd.systemImagePackage()] = true
+
+		systemImagePhase := "Updating system-image packages"
+		if installed, err := systemImageInstalled(androidHome, avd); err != nil {
+			log.Warnf("Failed to check if system image is installed: %s", err)
+		} else if !installed {
+			missingSystemImages++
+			systemImagePhase = fmt.Sprintf("Downloading missing system image (%s)", avd.systemImagePackage())
+		}
+		phases = append(phases, phase{
+			name:     systemImagePhase,
+			command:  installPackageCommand(ctx, sdkManagerPath, cfg.EmulatorChannel, avd.systemImagePackage()),
+			streamed: true,
+		})
+	}
+
+	restoredAVDs := map[string]bool{}
+	// the AVDs created by the step are deleted by the terminate mode, unless they are kept for caching
+	createdAVDs := map[string]bool{}
+	keepAVDs := cfg.CacheSnapshot || cfg.AVDCacheDir != ""
+	if cfg.AVDCacheDir != "" && !cfg.DryRun {
+		log.Infof("Restoring AVDs from the cache")
+		for _, avd := range avds {
+			if reusedSerials[avd.name] != "" {
+				continue
+			}
+			restored, err := restoreAVDCache(cfg.AVDCacheDir, avd)
+			if err != nil {
+				log.Warnf("Failed to restore AVD (%s) from the cache: %s", avd.name, err)
+			} else if !restored {
+				log.Printf("- No cached AVD (%s) for the current inputs", avd.name)
+			} else {
+				log.Printf("- AVD (%s) restored from %s", avd.name, avdCacheArchivePath(cfg.AVDCacheDir, avd))
+			}
+			restoredAVDs[avd.name] = restored
+		}
+		fmt.Println()
+	}
+
+	for _, avd := range avds {
+		if restoredAVDs[avd.name] || reusedSerials[avd.name] != "" {
+			// the archive name is derived from the inputs the AVD was created from
+			continue
+		}
+		// recreating the AVD would drop its data and snapshots
+		reuseAVD := false
+		if !cfg.WipeData {
+			exists, err := avdExists(avd.name)
+			if err != nil {
+				log.Warnf("Failed to check if AVD (%s) exists: %s", avd.name, err)
+			}
+			reuseAVD = exists
+		}
+		if reuseAVD {
+			// an AVD created by another configuration (system image, ABI, profile, SD card or create flags) can't be reused
+			mismatches, err := existingAVDMismatches(avd)
+			if err != nil {
+				log.Warnf("Failed to verify the existing AVD (%s), re-creating it: %s", avd.name, err)
+				reuseAVD = false
+			} else if len(mismatches) > 0 {
+				log.Warnf("The existing AVD (%s) doesn't match the inputs, re-creating it:", avd.name)
+				for _, mismatch := range mismatches {
+					log.Warnf("- %s", mismatch)
+				}
+				reuseAVD = false
+			}
+		}
+		if reuseAVD {
+			log.Printf("Data wiping is disabled, reusing the existing AVD (%s) to keep its data and snapshots, its configuration matches the inputs", avd.name)
+			fmt.Println()
+		} else {
+			phases = append(phases, phase{name: fmt.Sprintf("Creating device (%s)", avd.name), command: createAVDCommand(ctx, avdManagerPath, avd)})
+			createdAVDs[avd.name] = true
+		}
+	}
+
+	// a full disk fails the download or the emulator with an I/O error only minutes later
+	if cfg.CheckDiskSpace && !cfg.DryRun {
+		if avdHome, err := emulatormanager.AVDHome(); err != nil {
+			log.Warnf("Failed to check free disk space: %s", err)
+		} else {
+			requirements := []diskRequirement{{dir: androidHome, megabytes: int64(missingSystemImages) * systemImageDiskMB}}
+			for _, avd := range avds {
+				if reusedSerials[avd.name] != "" {
+					continue
+				}
+				requirements = append(requirements, diskRequirement{dir: avdHome, megabytes: avdDiskRequirement(cfg.DataPartitionSize, avd.sdcardSize)})
+			}
+			if err := checkDiskSpace(requirements); err != nil {
+				failf("Disk space check failed: %s", err)
+			}
+		}
+	}
+
+	// the license prompts of sdkmanager would block the step on fresh runners
+	if len(phases) > 0 && !cfg.DryRun {
+		if cfg.LicenseHashes != "" {
+			hashes, err := parseLicenseHashes(cfg.LicenseHashes)
+			if err != nil {
+				failf("Issue with input: sdk_license_hashes: %s", err)
+			}
+			if err := writeLicenseHashes(androidHome, hashes); err != nil {
+				failf("Failed to write SDK license hashes: %s", err)
+			}
+		} else {
+			phases = append([]phase{{name: "Accepting SDK licenses", command: acceptLicensesCommand(ctx, sdkManagerPath)}}, phases...)
+		}
+	}
+
+	for _, phase := range phases {
+		// a killed phase would fail with a misleading error
+		if err := ctx.Err(); err != nil {
+			failf("Step aborted before %s: %s", strings.ToLower(phase.name), err)
+		}
 		log.Infof(phase.name)
 		log.Donef("$ %s", phase.command.PrintableCommandArgs())
 
-		if out, err := phase.command.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		if cfg.DryRun {
+			fmt.Println()
+			continue
+		}
+		if phase.streamed {
+			if err := phase.command.Run(); err != nil {
+				failf("Failed to run phase: %s", err)
+			}
+		} else if out, err := phase.command.RunAndReturnTrimmedCombinedOutput(); err != nil {
 			failf("Failed to run phase: %s, output: %s", err, out)
 		}
 
 		fmt.Println()
 	}
 
-	args := append([]string{
-		"@" + cfg.ID,
-		"-verbose",
-		"-show-kernel",
-		"-no-audio",
-		"-no-window",
-		"-no-boot-anim",
-		"-netdelay", "none",
-		"-no-snapshot",
-		"-wipe-data",
-		"-gpu", "auto"}, startCustomFlags...)
+	if resolvedPath, err := emulatormanager.FindEmulator(androidHome, cfg.AndroidSDKRoot, cfg.AndroidHome); err == nil {
+		emulatorPath = resolvedPath
+	} else if cfg.DryRun {
+		log.Warnf("%s", err)
+		fmt.Println()
+	} else {
+		failf("%s", err)
+	}
 
-	serial := startEmulator(emulatorPath, args, androidHome, runningDevices, 1)
+	if cfg.EmulatorVersion != "" && !cfg.DryRun {
+		version, err := emulatorVersion(emulatorPath)
+		if err != nil {
+			failf("Failed to check emulator version: %s", err)
+		}
+		if !emulatorVersionMatches(version, cfg.EmulatorVersion) {
+			failf("Issue with input: emulator_version: emulator %s is installed instead of %s, select the Emulator channel which ships this version or preinstall it on the runner and disable the Emulator update", version, cfg.EmulatorVersion)
+		}
+		log.Printf("Using emulator %s", version)
+		fmt.Println()
+	}
 
-	if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_SERIAL", serial); err != nil {
-		log.Warnf("Failed to export environment (BITRISE_EMULATOR_SERIAL), error: %s", err)
+	if cfg.DisableMetrics {
+		// older emulators fail to start with the unknown flag, the dry run might not have an emulator to check
+		supported := cfg.DryRun
+		if !cfg.DryRun {
+			if supported, err = emulatorAcceptsFlag(emulatorPath, noMetricsFlag); err != nil {
+				log.Warnf("Failed to check the flags of the emulator: %s", err)
+			}
+		}
+		if supported {
+			startCustomFlags = enforceMetricsOptOut(startCustomFlags)
+		} else {
+			log.Warnf("The installed emulator doesn't support the %s flag, its metrics can't be disabled", noMetricsFlag)
+		}
 	}
-	log.Printf("- Device with serial: %s started", serial)
+	log.Printf("Emulator usage metrics: %s", metricsStatus(startCustomFlags))
+	fmt.Println()
 
-	log.Donef("- Done")
-}
+	if !hardware.empty() {
+		for _, avd := range avds {
+			if reusedSerials[avd.name] != "" {
+				continue
+			}
+			log.Infof("Applying hardware profile overrides (%s)", avd.name)
+			if cfg.DryRun {
+				log.Printf("Dry run: skipping the update of the AVD config")
+				fmt.Println()
+				continue
+			}
+			if err := applyHardwareOverrides(avd.name, hardware); err != nil {
+				failf("Failed to apply hardware profile overrides: %s", err)
+			}
+			fmt.Println()
+		}
+	}
 
-func startEmulator(emulatorPath string, args []string, androidHome string, runningDevices map[string]string, attempt int) string {
-	var output bytes.Buffer
-	deviceStartCmd := command.New(emulatorPath, args...).SetStdout(&output).SetStderr(&output)
+	accelerated := false
+	for _, abi := range abis {
+		accelerated = accelerated || requiresAcceleration(abi, hostIsARM)
+	}
+	if !cfg.DryRun {
+		for _, avd := range avds {
+			if err := emulatormanager.CheckAVDExists(emulatorPath, avd.name); err != nil {
+				failf("Failed to start emulator: %s", err)
+			}
+		}
+	}
 
-	log.Infof("Starting device")
-	log.Donef("$ %s", deviceStartCmd.PrintableCommandArgs())
+	if cfg.CheckAcceleration && accelerated && !cfg.DryRun {
+		log.Infof("Checking hardware acceleration")
+		if err := checkAcceleration(emulatorPath); err != nil && cfg.AccelFallback == "software" {
+			log.Warnf("%s", err)
+			log.Warnf("Falling back to software emulation (-accel off): the boot and the tests are many times slower, increase the boot timeouts accordingly")
+			startCustomFlags = append([]string{"-accel", "off"}, startCustomFlags...)
+		} else if err != nil {
+			failf("%s", err)
+		}
+		fmt.Println()
+	}
 
-	// The emulator command won't exit after the boot completes, so we start the command and not wait for its result.
-	// Instead, we have a loop with 3 channels:
-	// 1. One that waits for the emulator process to exit
-	// 2. A boot timeout timer
-	// 3. A ticker that periodically checks if the device has become online
-	if err := deviceStartCmd.GetCmd().Start(); err != nil {
-		failf("Failed to run device start command: %v", err)
+	// parallel emulators get distinct ports, so that each device can be matched by its serial
+	ports := make([]int, len(avds))
+	// the gRPC endpoint of every emulator gets its own port, derived from the console port
+	avdCustomFlags := func(port int) []string {
+		if !cfg.GRPCControl {
+			return startCustomFlags
+		}
+		return append(grpcFlags(port), startCustomFlags...)
+	}
+	if cfg.Port != "" || len(avds) > 1 {
+		r := emulatormanager.PortRange{First: emulatormanager.FirstEmulatorPort, Last: emulatormanager.LastEmulatorPort}
+		if cfg.Port != "" {
+			r, err = emulatormanager.ParsePortRange(cfg.Port, len(avds))
+			if err != nil {
+				failf("Issue with input: port: %s", err)
+			}
+		}
+		ports, err = emulatormanager.AllocatePorts(runningDevices, len(avds), r)
+		if err != nil {
+			failf("Failed to allocate emulator ports: %s", err)
+		}
 	}
 
-	emulatorWaitCh := make(chan error, 1)
-	go func() {
-		emulatorWaitCh <- deviceStartCmd.GetCmd().Wait()
-	}()
+	if cfg.DryRun {
+		for i, avd := range avds {
+			if serial := reusedSerials[avd.name]; serial != "" {
+				log.Infof("Reusing device (%s)", avd.name)
+				log.Printf("Dry run: skipping the readiness checks of %s", serial)
+				fmt.Println()
+				continue
+			}
+			opts := emulatormanager.BootOptions{
+				AVDName:       avd.name,
+				Port:          ports[i],
+				DefaultFlags:  startFlags,
+				GPUMode:       cfg.GPUMode,
+				DisabledFlags: disabledFlags,
+				CustomFlags:   avdCustomFlags(ports[i]),
+			}
+			log.Infof("Starting device (%s)", avd.name)
+			log.Donef("$ %s", command.New(emulatorPath, opts.Args()...).PrintableCommandArgs())
+			fmt.Println()
+		}
+		log.Donef("Dry run finished, no command was executed")
+		return
+	}
 
-	timeoutTimer := time.NewTimer(bootTimeout)
+	var events *eventLog
+	if cfg.JSONEvents {
+		events, err = newEventLog(cfg.DeployDir)
+		if err != nil {
+			failf("Failed to create event log: %s", err)
+		}
+	}
+	if cfg.StatusFile != "" {
+		var avdNames []string
+		for _, avd := range avds {
+			avdNames = append(avdNames, avd.name)
+		}
+		status, err = newStatusFile(cfg.StatusFile, avdNames)
+		if err != nil {
+			failf("Issue with input: status_file: %s", err)
+		}
+		log.Printf("Emulator status is written to: %s", cfg.StatusFile)
+		if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_STATUS_FILE", cfg.StatusFile); err != nil {
+			log.Warnf("Failed to export environment (BITRISE_EMULATOR_STATUS_FILE), error: %s", err)
+		}
+		fmt.Println()
+	}
+	onEvent := func(event emulatormanager.Event) {
+		events.emit(event)
+		status.update(event)
+	}
 
-	deviceCheckTicker := time.NewTicker(deviceCheckInterval)
+	boot := emulatormanager.BootCompletion{
+		Timeout:      time.Duration(cfg.BootTimeout) * time.Second,
+		FormFactor:   formFactor,
+		PlayStore:    emulatormanager.IsPlayStoreImage(cfg.Tag),
+		StuckTimeout: time.Duration(cfg.StuckBootTimeout) * time.Second,
+	}
+	var waitBoot *emulatormanager.BootCompletion
+	if cfg.WaitBootComplete {
+		waitBoot = &boot
+	}
 
-	var serial string
-	retry := false
-waitLoop:
-	for {
-		select {
-		case err := <-emulatorWaitCh:
-			log.Warnf("Emulator process exited early")
-			if err != nil {
-				log.Errorf("Emulator exit reason: %v", err)
-			} else {
-				log.Warnf("A possible cause can be the emulator process having received a KILL signal.")
-			}
-			log.Printf("Emulator log: %s", output)
-			failf("Emulator exited early, see logs above.")
-		case <-timeoutTimer.C:
-			// Include error before and after printing the emulator log because it's so long
-			errorMsg := fmt.Sprintf("Failed to boot emulator device within %d seconds.", bootTimeout/time.Second)
-			log.Errorf(errorMsg)
-			log.Printf("Emulator log: %s", output)
-			failf(errorMsg)
-		case <-deviceCheckTicker.C:
+	policy := emulatormanager.RetryPolicy{
+		MaxAttempts:  cfg.MaxBootAttempts,
+		InitialDelay: emulatormanager.BootRetryInitialDelay,
+		MaxDelay:     emulatormanager.BootRetryMaxDelay,
+	}
+	diagnostics := failureDiagnostics{
+		dir:        cfg.DeployDir,
+		bugreport:  cfg.BugreportOnError,
+		screenshot: cfg.ScreenshotOnError,
+		crashDumps: cfg.CrashDumpsOnError,
+		grpc:       cfg.GRPCControl,
+	}
+	resourceMonitors := make([]*resourceMonitor, len(avds))
+	if cfg.ResourceMetrics != "no" {
+		for i, avd := range avds {
+			resourceMonitors[i] = startResourceMonitor(avd.name)
+		}
+	}
+	reportResources := func() {
+		if cfg.ResourceMetrics == "no" {
+			return
+		}
+		fmt.Println()
+		log.Infof("Emulator resource usage")
+		for _, monitor := range resourceMonitors {
+			samples := monitor.stop()
+			printResourceSummary(monitor.avdName, samples)
+			if cfg.ResourceMetrics == "csv" && len(samples) > 0 {
+				if pth, err := writeResourceCSV(cfg.DeployDir, monitor.avdName, samples); err != nil {
+					log.Warnf("Failed to write resource metrics (%s): %s", monitor.avdName, err)
+				} else {
+					log.Printf("  Samples written to: %s", pth)
+				}
+			}
+		}
+	}
+
+	// the location is lost with the emulator process, so it is set by every completed boot attempt
+	var onBootCompleted func(serial string) error
+	if cfg.GPSLocation != "" {
+		// the location input is validated on startup
+		location, _ := parseGPSLocation(cfg.GPSLocation)
+		setLocation := setGPSLocation(location)
+		onBootCompleted = func(serial string) error {
+			log.Printf("Setting GPS location (%s)", serial)
+			if err := setLocation(adbClient, serial); err != nil {
+				return fmt.Errorf("failed to set GPS location: %s", err)
+			}
+			return nil
+		}
+	}
+
+	results := make([]bootResult, len(avds))
+	bootErrs := make([]error, len(avds))
+	var wg sync.WaitGroup
+	for i, avd := range avds {
+		wg.Add(1)
+		go func(i int, avd avdSpec) {
+			defer wg.Done()
+			logPath := emulatorLogPath(avd)
+			startTime := time.Now()
+			var emulatorPID int
+			var device emulatormanager.Device
 			var err error
-			serial, err = queryNewDeviceSerial(androidHome, runningDevices)
-			if err != nil {
-				failf("Error: %s", err)
-			} else if serial != "" {
-				break waitLoop
-			}
-			if containsAny(output.String(), faultIndicators) {
-				log.Warnf("Emulator log contains fault")
-				log.Warnf("Emulator log: %s", output)
-				if err := deviceStartCmd.GetCmd().Process.Kill(); err != nil {
-					failf("Couldn't finish emulator process: %v", err)
+			if serial := reusedSerials[avd.name]; serial != "" {
+				device, err = reuseDevice(ctx, adbClient, avd.name, serial, waitBoot)
+				if err == nil && onBootCompleted != nil {
+					err = onBootCompleted(serial)
+				}
+				if err == nil {
+					onEvent(emulatormanager.Event{Time: time.Now(), Type: emulatormanager.EventBootCompleted, AVDName: avd.name, Serial: serial})
+				}
+				if err == nil && resourceMonitors[i] != nil && device.PID != 0 {
+					resourceMonitors[i].track(device.PID)
+				}
+			} else {
+				device, err = emulatormanager.Boot(ctx, adbClient, emulatormanager.BootOptions{
+					EmulatorPath:     emulatorPath,
+					AVDName:          avd.name,
+					Port:             ports[i],
+					DefaultFlags:     startFlags,
+					GPUMode:          cfg.GPUMode,
+					DisabledFlags:    disabledFlags,
+					CustomFlags:      avdCustomFlags(ports[i]),
+					RetryPolicy:      policy,
+					RunningDevices:   runningDevices,
+					DetectionTimeout: time.Duration(cfg.DetectionTimeout) * time.Second,
+					Polling: emulatormanager.DevicePolling{
+						Interval:            time.Duration(cfg.PollInterval) * time.Second,
+						ADBRestartThreshold: cfg.ADBRestartPolls,
+					},
+					LogPath:      logPath,
+					LogVerbosity: emulatormanager.LogVerbosity(cfg.LogVerbosity),
+					OnFailure: func(serial string, online bool) {
+						diagnostics.collect(adbClient, serial, emulatorPID, online)
+					},
+					Boot:            waitBoot,
+					OnEvent:         onEvent,
+					OnBootCompleted: onBootCompleted,
+					OnProcessStarted: func(pid int) {
+						emulatorPID = pid
+						if resourceMonitors[i] != nil {
+							resourceMonitors[i].track(pid)
+						}
+					},
+				})
+			}
+			// the gRPC and qemu lookups below are not part of the boot
+			bootCompleted := time.Now()
+			result := newBootResult(device)
+			result.ABI = avd.abi
+			if err == nil && cfg.GRPCControl {
+				if client, port, grpcErr := dialGRPC(ctx, device.PID); grpcErr != nil {
+					log.Warnf("Failed to connect to the gRPC endpoint of %s: %s", avd.name, grpcErr)
+				} else {
+					result.GRPCPort = port
+					if closeErr := client.Close(); closeErr != nil {
+						log.Warnf("Failed to close emulator gRPC connection: %s", closeErr)
+					}
 				}
-				if attempt < maxBootAttempts {
-					log.Warnf("Trying to start emulator process again...")
-					retry = true
-					break waitLoop
+			}
+			result.APILevel = avd.apiLevel
+			result.Profile = avd.profile
+			// the qemu lookup relies on ps
+			if err == nil && runtime.GOOS != "windows" {
+				if qemuPID, qemuErr := findQEMUProcess(device.PID); qemuErr != nil {
+					log.Warnf("Failed to find the qemu process of %s: %s", avd.name, qemuErr)
 				} else {
-					failf("Failed to boot device due to faults after %d tries", maxBootAttempts)
+					result.QEMUPID = qemuPID
 				}
 			}
+			result.timings.bootCompleted = bootCompleted
+			result.BootDurationSeconds = bootCompleted.Sub(startTime).Seconds()
+			results[i], bootErrs[i] = result, err
+		}(i, avd)
+	}
+	wg.Wait()
+
+	// the booted devices are recorded before any failure, so the terminate mode stops them too
+	var sessionDevices []sessionDevice
+	for i, err := range bootErrs {
+		if err == nil && reusedSerials[avds[i].name] == "" {
+			device := sessionDevice{
+				AVDName:   avds[i].name,
+				Serial:    results[i].Serial,
+				PID:       results[i].PID,
+				DeleteAVD: createdAVDs[avds[i].name] && !keepAVDs,
+			}
+			if cfg.AVDCacheDir != "" {
+				device.CacheArchive = avdCacheArchivePath(cfg.AVDCacheDir, avds[i])
+			}
+			sessionDevices = append(sessionDevices, device)
 		}
 	}
-	timeoutTimer.Stop()
-	deviceCheckTicker.Stop()
-	if retry {
-		return startEmulator(emulatorPath, args, androidHome, runningDevices, attempt+1)
+	if len(sessionDevices) > 0 {
+		recordSession(sessionDevices, nil)
 	}
-	return serial
-}
 
-func containsAny(output string, any []string) bool {
-	for _, fault := range any {
-		if strings.Contains(output, fault) {
-			return true
+	var failures []string
+	for i, err := range bootErrs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", avds[i].name, err))
+		}
+	}
+	if len(failures) > 0 {
+		reportResources()
+		failf("Failed to start emulator:\n%s", strings.Join(failures, "\n"))
+	}
+
+	var serials []string
+	for _, result := range results {
+		serials = append(serials, result.Serial)
+	}
+
+	setUpDevices(ctx, cfg, adbClient, serials, boot, inputs)
+	// the setup covers every action since the boot (the system modification, the certificate and root access included)
+	setupFinished := time.Now()
+	for i := range results {
+		results[i].timings.ready = setupFinished
+	}
+	if cfg.DeviceReport {
+		fmt.Println()
+		for _, serial := range serials {
+			report := collectDeviceReport(adbClient, serial)
+			report.print(serial)
+			if pth, err := report.write(cfg.DeployDir, serial); err != nil {
+				log.Warnf("Failed to write device report (%s): %s", serial, err)
+			} else {
+				log.Printf("- Full report saved to: %s", pth)
+			}
 		}
 	}
+	for i := range results {
+		results[i].Timings = results[i].timings.report()
+		onEvent(deviceReadyEvent(results[i]))
+	}
+	status.finish(statusReady, "")
+	printTimings(results)
+	exportTimings(results)
+	fmt.Println()
+
+	// the monitor starts once the step's own reboots and configuration are over
+	if cfg.MonitorHealth {
+		startHealthMonitors(adbClient, cfg.DeployDir, serials, cfg.CrashDumpsOnError)
+	}
 
-	return false
+	for _, serial := range serials {
+		log.Printf("- Device with serial: %s started", serial)
+	}
+
+	exportResults(cfg.DeployDir, results)
+
+	if cfg.ManagedDevices {
+		exportManagedDevices(cfg.DeployDir, avdManagerPath, avds, results)
+	}
+
+	if cfg.CacheSnapshot {
+		cacheSnapshots(ctx, adbClient, cfg.SnapshotName, avds, results)
+	}
+
+	// the disk images of the running emulators are not archived, the terminate mode archives them once the emulators are stopped
+	if cfg.AVDCacheDir != "" {
+		fmt.Println()
+		log.Infof("Caching AVDs")
+		log.Printf("- The AVDs will be archived to %s by the terminate mode of the step", cfg.AVDCacheDir)
+	}
+
+	reportResources()
+
+	events.close()
+	log.Donef("- Done")
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matrixEntry is an API level and device profile pair of the device matrix.
+type matrixEntry struct {
+	apiLevel int
+	profile  string
+}
+
+// nonNameCharacters matches the characters of a profile which can't be part of an AVD name.
+var nonNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// parseDeviceMatrix parses the newline separated list of <API level>:<profile> pairs.
+func parseDeviceMatrix(list string) ([]matrixEntry, error) {
+	var entries []matrixEntry
+	seen := map[string]bool{}
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		separator := strings.Index(line, ":")
+		if separator < 0 {
+			return nil, fmt.Errorf("invalid entry (%s), expected format: <API level>:<profile>", line)
+		}
+		apiLevel, err := strconv.Atoi(strings.TrimSpace(line[:separator]))
+		if err != nil || apiLevel < 1 {
+			return nil, fmt.Errorf("invalid API level in entry: %s", line)
+		}
+		entry := matrixEntry{apiLevel: apiLevel, profile: strings.TrimSpace(line[separator+1:])}
+		if entry.profile == "" {
+			return nil, fmt.Errorf("missing profile in entry: %s", line)
+		}
+		if seen[entry.name()] {
+			return nil, fmt.Errorf("entry is listed multiple times: %s", line)
+		}
+		seen[entry.name()] = true
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// name returns the AVD name suffix of the entry, like 30_pixel_6.
+func (e matrixEntry) name() string {
+	return fmt.Sprintf("%d_%s", e.apiLevel, strings.Trim(nonNameCharacters.ReplaceAllString(e.profile, "_"), "_"))
+}
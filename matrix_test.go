@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []matrixEntry
+		wantErr bool
+	}{
+		{name: "empty list"},
+		{
+			name: "entries",
+			list: "30:pixel\n33:pixel_6",
+			want: []matrixEntry{{apiLevel: 30, profile: "pixel"}, {apiLevel: 33, profile: "pixel_6"}},
+		},
+		{
+			name: "blank lines and spaces",
+			list: "\n  30 : pixel  \n\n",
+			want: []matrixEntry{{apiLevel: 30, profile: "pixel"}},
+		},
+		{
+			name: "same profile on multiple API levels",
+			list: "30:pixel\n31:pixel",
+			want: []matrixEntry{{apiLevel: 30, profile: "pixel"}, {apiLevel: 31, profile: "pixel"}},
+		},
+		{name: "missing separator", list: "30 pixel", wantErr: true},
+		{name: "invalid API level", list: "R:pixel", wantErr: true},
+		{name: "zero API level", list: "0:pixel", wantErr: true},
+		{name: "missing profile", list: "30:", wantErr: true},
+		{name: "duplicate entry", list: "30:pixel\n30:pixel", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDeviceMatrix(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDeviceMatrix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDeviceMatrix() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatrixEntryName(t *testing.T) {
+	tests := []struct {
+		entry matrixEntry
+		want  string
+	}{
+		{entry: matrixEntry{apiLevel: 30, profile: "pixel_6"}, want: "30_pixel_6"},
+		{entry: matrixEntry{apiLevel: 33, profile: "Nexus 5X"}, want: "33_Nexus_5X"},
+		{entry: matrixEntry{apiLevel: 29, profile: "(tv) 1080p"}, want: "29_tv_1080p"},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.name(); got != tt.want {
+			t.Errorf("name() of %+v = %s, want %s", tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// bootTimings are the timestamps of the startup milestones of a device.
+type bootTimings struct {
+	processStarted time.Time
+	deviceDetected time.Time
+	bootCompleted  time.Time
+	ready          time.Time
+}
+
+// timingReport holds the durations of the startup phases of a device in seconds.
+type timingReport struct {
+	// DetectionSeconds is the time from the (last) emulator process start until the device is online in adb.
+	DetectionSeconds float64 `json:"detection_seconds"`
+	// BootCompleteSeconds is the time from the device detection until the boot is completed.
+	BootCompleteSeconds float64 `json:"boot_complete_seconds"`
+	// SetupSeconds is the time from the boot completion until the last post-boot setup action finished.
+	SetupSeconds float64 `json:"setup_seconds"`
+	// TotalSeconds is the time from the (last) emulator process start until the device is ready.
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+func (t bootTimings) report() timingReport {
+	return timingReport{
+		DetectionSeconds:    t.deviceDetected.Sub(t.processStarted).Seconds(),
+		BootCompleteSeconds: t.bootCompleted.Sub(t.deviceDetected).Seconds(),
+		SetupSeconds:        t.ready.Sub(t.bootCompleted).Seconds(),
+		TotalSeconds:        t.ready.Sub(t.processStarted).Seconds(),
+	}
+}
+
+// printTimings prints the startup timing breakdown of the devices.
+func printTimings(results []bootResult) {
+	log.Infof("Startup timings")
+	for _, result := range results {
+		report := result.Timings
+		log.Printf("%s (%s):", result.Serial, result.AVDName)
+		log.Printf("- device detected: %.1fs", report.DetectionSeconds)
+		log.Printf("- boot completed:  %.1fs", report.BootCompleteSeconds)
+		log.Printf("- post-boot setup: %.1fs", report.SetupSeconds)
+		log.Printf("- total:           %.1fs", report.TotalSeconds)
+	}
+}
+
+// exportTimings exports the startup durations of the slowest device as step outputs.
+func exportTimings(results []bootResult) {
+	var slowest timingReport
+	for _, result := range results {
+		if result.Timings.TotalSeconds >= slowest.TotalSeconds {
+			slowest = result.Timings
+		}
+	}
+
+	for key, seconds := range map[string]float64{
+		"BITRISE_EMULATOR_DETECTION_DURATION":     slowest.DetectionSeconds,
+		"BITRISE_EMULATOR_BOOT_COMPLETE_DURATION": slowest.BootCompleteSeconds,
+		"BITRISE_EMULATOR_SETUP_DURATION":         slowest.SetupSeconds,
+		"BITRISE_EMULATOR_TOTAL_DURATION":         slowest.TotalSeconds,
+	} {
+		if err := tools.ExportEnvironmentWithEnvman(key, fmt.Sprintf("%.1f", seconds)); err != nil {
+			log.Warnf("Failed to export environment (%s), error: %s", key, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+	"github.com/kballard/go-shellquote"
+)
+
+const (
+	healthCheckIntervalSeconds = 10
+	// healthCheckFailures consecutive failing checks mark the device dead, so an intentional reboot isn't reported
+	healthCheckFailures = 6
+
+	healthStatusRunning = "running"
+	healthStatusDead    = "dead"
+)
+
+// healthFilePath returns where the health status of the device is written in the deploy directory.
+func healthFilePath(deployDir, serial string) string {
+	return filepath.Join(deployDir, fmt.Sprintf("emulator-health-%s.txt", serial))
+}
+
+// healthCrashDumpsPath returns where the health monitor pulls the crash dumps of the dead device in the deploy directory.
+func healthCrashDumpsPath(deployDir, serial string) string {
+	return filepath.Join(deployDir, fmt.Sprintf("emulator-crash-dumps-%s-health", serial))
+}
+
+// startHealthMonitor starts a detached process which keeps checking the adb state of the device for the rest of the build.
+// The status file contains "running" while the device is online; once the device is gone, it is overwritten with
+// "dead <UTC timestamp> <last adb state>" and the monitor exits. Later steps can check it to learn that the emulator died mid-test.
+// If crashDumpsPath is set, the monitor tries to pull the tombstones and the ANR traces into it before reporting the dead device.
+func startHealthMonitor(adbClient emulatormanager.ADB, serial, path, crashDumpsPath string) (int, error) {
+	getState := shellquote.Join(adbClient.Path, "-s", serial, "get-state")
+	status := shellquote.Join(path)
+	// adb is usually gone together with the device, so the pull is best effort
+	pullCrashDumps := ":"
+	if crashDumpsPath != "" {
+		pullCrashDumps = "mkdir -p " + shellquote.Join(crashDumpsPath)
+		for _, dir := range crashDumpDirs {
+			pullCrashDumps += fmt.Sprintf("; %s > /dev/null 2>&1", shellquote.Join(adbClient.Path, "-s", serial, "pull", dir, crashDumpsPath))
+		}
+	}
+	script := fmt.Sprintf(`echo %s > %s
+failures=0
+while true; do
+  state=$(%s 2>&1)
+  if [ "$state" = "device" ]; then failures=0; else failures=$((failures+1)); fi
+  if [ $failures -ge %d ]; then
+    %s
+    echo "%s $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) $state" > %s
+    exit 0
+  fi
+  sleep %d
+done`, healthStatusRunning, status, getState, healthCheckFailures, pullCrashDumps, healthStatusDead, status, healthCheckIntervalSeconds)
+
+	cmd := command.New("/bin/sh", "-c", script)
+	// the terminate mode stops the whole process group
+	emulatormanager.StartInProcessGroup(cmd.GetCmd())
+	if err := cmd.GetCmd().Start(); err != nil {
+		return 0, fmt.Errorf("failed to start health monitor: %s", err)
+	}
+
+	pid := cmd.GetCmd().Process.Pid
+	if err := cmd.GetCmd().Process.Release(); err != nil {
+		return pid, fmt.Errorf("failed to release health monitor process: %s", err)
+	}
+	return pid, nil
+}
+
+// startHealthMonitors starts the health monitor of every device, records them in the session
+// and exports the health status files as a step output.
+func startHealthMonitors(adbClient emulatormanager.ADB, deployDir string, serials []string, crashDumps bool) {
+	var healthFiles []string
+	for _, serial := range serials {
+		pth := healthFilePath(deployDir, serial)
+		crashDumpsPth := ""
+		if crashDumps {
+			crashDumpsPth = healthCrashDumpsPath(deployDir, serial)
+		}
+		pid, err := startHealthMonitor(adbClient, serial, pth, crashDumpsPth)
+		if err != nil {
+			log.Warnf("Failed to start health monitor (%s): %s", serial, err)
+			continue
+		}
+		recordSession(nil, []sessionProcess{{Name: "health monitor", Serial: serial, PID: pid, PGID: pid}})
+		log.Printf("- Health status of %s is written to: %s", serial, pth)
+		healthFiles = append(healthFiles, pth)
+	}
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_HEALTH_FILES", strings.Join(healthFiles, ",")); err != nil {
+		log.Warnf("Failed to export environment (BITRISE_EMULATOR_HEALTH_FILES), error: %s", err)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// parsePackageNames parses the comma or newline separated list of package names.
+func parsePackageNames(list string) []string {
+	var packages []string
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			packages = append(packages, item)
+		}
+	}
+	return packages
+}
+
+// runtimePermissions returns the runtime permissions of the dumpsys package output which are not granted yet.
+// They are listed as <permission>: granted=false under the runtime permissions section of every user.
+func runtimePermissions(dumpsys string) []string {
+	var permissions []string
+	seen := map[string]bool{}
+	inSection := false
+	for _, line := range strings.Split(dumpsys, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, "runtime permissions:") {
+			inSection = true
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		separator := strings.Index(trimmed, ": granted=")
+		if separator < 0 {
+			inSection = false
+			continue
+		}
+		permission := trimmed[:separator]
+		if strings.HasPrefix(trimmed[separator:], ": granted=false") && !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+// grantRuntimePermissions grants every runtime permission the installed packages declare, so tests don't stop at permission dialogs.
+func grantRuntimePermissions(adbClient emulatormanager.ADB, serial string, packages []string) error {
+	for _, pkg := range packages {
+		out, err := adbClient.Shell(serial, "dumpsys", "package", pkg)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(out, "Unable to find package") {
+			return fmt.Errorf("package (%s) is not installed", pkg)
+		}
+
+		permissions := runtimePermissions(out)
+		for _, permission := range permissions {
+			if _, err := adbClient.Shell(serial, "pm", "grant", pkg, permission); err != nil {
+				return fmt.Errorf("failed to grant %s to %s: %s", permission, pkg, err)
+			}
+		}
+		log.Printf("- %d runtime permission(s) granted to %s (%s)", len(permissions), pkg, serial)
+	}
+	return nil
+}
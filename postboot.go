@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const (
+	screenCheckAttempts = 5
+	screenCheckInterval = time.Duration(2) * time.Second
+)
+
+// postBootAction is a device configuration run once the device has booted.
+type postBootAction struct {
+	name string
+	run  func(adbClient emulatormanager.ADB, serial string) error
+}
+
+// postBootActions returns the actions enabled by the step inputs.
+func postBootActions(cfg config) []postBootAction {
+	var actions []postBootAction
+	if cfg.DisableAnimations {
+		actions = append(actions, postBootAction{name: "Disabling animations", run: disableAnimations})
+	}
+	if cfg.NoSoftKeyboard {
+		actions = append(actions, postBootAction{name: "Disabling soft keyboard and autofill", run: disableSoftKeyboard})
+	}
+	if cfg.NightMode != "default" {
+		actions = append(actions, postBootAction{name: "Setting night mode", run: setNightMode(cfg.NightMode == "yes")})
+	}
+	// the font scale input is validated on startup
+	if scale, err := parseFontScale(cfg.FontScale); err == nil && scale > 0 {
+		actions = append(actions, postBootAction{name: "Setting font scale", run: setFontScale(scale)})
+	}
+	if cfg.DisplayDensity > 0 {
+		actions = append(actions, postBootAction{name: "Setting display density", run: setDisplayDensity(cfg.DisplayDensity)})
+	}
+	if cfg.NoPackageVerifier {
+		actions = append(actions, postBootAction{name: "Disabling package verification", run: disablePackageVerification})
+	}
+	if cfg.HTTPProxy != "" {
+		// the proxy input is validated on startup
+		if hostPort, err := proxyHostPort(cfg.HTTPProxy); err == nil {
+			actions = append(actions, postBootAction{name: "Setting global HTTP proxy", run: setGlobalProxy(hostPort)})
+		}
+	}
+	// the battery level input is validated on startup
+	if level, err := parseBatteryLevel(cfg.BatteryLevel); err == nil {
+		battery := batteryState{level: level, charging: cfg.BatteryCharging, health: cfg.BatteryHealth}
+		if battery.isSet() {
+			actions = append(actions, postBootAction{name: "Setting battery state", run: setBatteryState(battery)})
+		}
+	}
+	// the event list is validated on startup
+	if events, err := parseTelephonyEvents(cfg.TelephonyEvents); err == nil && len(events) > 0 {
+		actions = append(actions, postBootAction{name: "Sending telephony events", run: sendTelephonyEvents(events)})
+	}
+	if cfg.FoldPosture != "default" {
+		actions = append(actions, postBootAction{name: "Setting fold posture", run: setFoldPosture(cfg.FoldPosture)})
+	}
+	if cfg.UnlockScreen {
+		actions = append(actions, postBootAction{name: "Unlocking screen", run: unlockScreen})
+	}
+	// the screen lock PIN of the enrollment locks the keyguard, so the enrollment runs after the unlock
+	if cfg.FingerprintID > 0 {
+		actions = append(actions, postBootAction{name: "Enrolling fingerprint", run: enrollFingerprint(cfg.FingerprintID)})
+	}
+	return actions
+}
+
+// setUpDevices prepares the booted devices in order: the system modification, the CA certificate and root access
+// (which reboot the device), the captures, the post-boot actions, the APKs, the files, the permissions
+// and the accessibility services. The step fails at the first failing setup.
+func setUpDevices(ctx context.Context, cfg config, adbClient emulatormanager.ADB, serials []string, boot emulatormanager.BootCompletion, inputs bootInputs) {
+	// the system is modified first, so the certificate installation and root access run on the modified system
+	if cfg.WritableSystem {
+		fmt.Println()
+		log.Infof("Preparing writable system partition")
+		for _, serial := range serials {
+			if err := prepareWritableSystem(ctx, adbClient, serial, cfg.SystemScript, boot); err != nil {
+				failf("Failed to prepare writable system partition (%s): %s", serial, err)
+			}
+			log.Printf("- System partition prepared (%s)", serial)
+		}
+		fmt.Println()
+	}
+
+	// the certificate installation reboots the device, so it has to precede anything attached to the device
+	if cfg.CACertificate != "" {
+		fmt.Println()
+		log.Infof("Installing CA certificate")
+		for _, serial := range serials {
+			if err := installCACertificate(ctx, adbClient, serial, cfg.CACertificate, boot); err != nil {
+				failf("Failed to install CA certificate (%s): %s", serial, err)
+			}
+		}
+		fmt.Println()
+	}
+
+	// adbd is restarted as non-root on reboot, so root access is enabled after the certificate installation
+	if cfg.RootAccess {
+		fmt.Println()
+		log.Infof("Enabling root access")
+		for _, serial := range serials {
+			if err := emulatormanager.WaitForBootComplete(ctx, adbClient, serial, boot); err != nil {
+				failf("Failed to enable root access (%s): %s", serial, err)
+			}
+			if err := enableRootAccess(ctx, adbClient, serial, boot); err != nil {
+				failf("Failed to enable root access (%s): %s", serial, err)
+			}
+			log.Printf("- Root access enabled (%s)", serial)
+		}
+		fmt.Println()
+	}
+
+	if cfg.CaptureLogcat {
+		for _, serial := range serials {
+			pth := logcatPath(cfg.DeployDir, serial, len(serials) > 1)
+			if pid, err := startLogcat(adbClient, serial, pth); err != nil {
+				log.Warnf("Failed to start logcat capture (%s): %s", serial, err)
+			} else {
+				recordSession(nil, []sessionProcess{{Name: "logcat", Serial: serial, PID: pid, PGID: pid}})
+				log.Printf("- Logcat of %s is written to: %s", serial, pth)
+			}
+		}
+	}
+
+	if cfg.RecordScreen {
+		for _, serial := range serials {
+			pth := screenRecordingPath(cfg.DeployDir, serial)
+			if pid, err := startScreenRecording(adbClient, serial, pth); err != nil {
+				log.Warnf("Failed to start screen recording (%s): %s", serial, err)
+			} else {
+				recordSession(nil, []sessionProcess{{Name: "screen recording", Serial: serial, PID: pid, PGID: pid}})
+				log.Printf("- Screen of %s is recorded to: %s", serial, pth)
+			}
+		}
+	}
+
+	if actions := postBootActions(cfg); len(actions) > 0 {
+		fmt.Println()
+		log.Infof("Configuring device")
+		for _, serial := range serials {
+			if err := runPostBootActions(adbClient, serial, actions, time.Duration(cfg.PostBootTimeout)*time.Second); err != nil {
+				failf("Failed to configure device (%s): %s", serial, err)
+			}
+		}
+		fmt.Println()
+	}
+	if len(inputs.apkPaths) > 0 {
+		fmt.Println()
+		log.Infof("Installing APKs")
+		for _, serial := range serials {
+			if err := installAPKs(adbClient, serial, inputs.apkPaths); err != nil {
+				failf("Failed to install APKs (%s): %s", serial, err)
+			}
+		}
+		fmt.Println()
+	}
+	if len(inputs.filePushes) > 0 {
+		fmt.Println()
+		log.Infof("Pushing files")
+		for _, serial := range serials {
+			if err := pushFiles(adbClient, serial, inputs.filePushes); err != nil {
+				failf("Failed to push files (%s): %s", serial, err)
+			}
+		}
+		fmt.Println()
+	}
+	// the packages can come from the installed APKs
+	if packages := parsePackageNames(cfg.GrantPermissions); len(packages) > 0 {
+		fmt.Println()
+		log.Infof("Granting runtime permissions")
+		for _, serial := range serials {
+			if err := grantRuntimePermissions(adbClient, serial, packages); err != nil {
+				failf("Failed to grant runtime permissions (%s): %s", serial, err)
+			}
+		}
+		fmt.Println()
+	}
+	// the services can come from the installed APKs
+	if len(inputs.a11yServices) > 0 {
+		fmt.Println()
+		log.Infof("Enabling accessibility services")
+		for _, serial := range serials {
+			if err := enableAccessibilityServices(adbClient, serial, inputs.a11yServices); err != nil {
+				failf("Failed to enable accessibility services (%s): %s", serial, err)
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// runPostBootActions runs the actions on the device in order and stops at the first failing one.
+// The actions have timeout to finish altogether.
+func runPostBootActions(adbClient emulatormanager.ADB, serial string, actions []postBootAction, timeout time.Duration) error {
+	doneCh := make(chan error, 1)
+	go func() {
+		for _, action := range actions {
+			log.Printf("%s (%s)", action.name, serial)
+			if err := action.run(adbClient, serial); err != nil {
+				doneCh <- fmt.Errorf("%s failed: %s", action.name, err)
+				return
+			}
+		}
+		doneCh <- nil
+	}()
+
+	timeoutTimer := time.NewTimer(timeout)
+	defer timeoutTimer.Stop()
+
+	select {
+	case err := <-doneCh:
+		return err
+	case <-timeoutTimer.C:
+		return fmt.Errorf("post-boot setup phase timed out: the device configuration did not finish within %s", timeout)
+	}
+}
+
+func disableAnimations(adbClient emulatormanager.ADB, serial string) error {
+	for _, setting := range []string{"window_animation_scale", "transition_animation_scale", "animator_duration_scale"} {
+		if _, err := adbClient.Shell(serial, "settings", "put", "global", setting, "0"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// disablePackageVerification turns off Play Protect and the verification of adb installs, so the installation
+// of sideloaded test APKs is neither blocked nor slowed down on images with Google Play.
+func disablePackageVerification(adbClient emulatormanager.ADB, serial string) error {
+	for _, setting := range []string{"package_verifier_enable", "verifier_verify_adb_installs"} {
+		if _, err := adbClient.Shell(serial, "settings", "put", "global", setting, "0"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlockScreen wakes the device up, dismisses the keyguard and keeps the screen on while the device is charging.
+func unlockScreen(adbClient emulatormanager.ADB, serial string) error {
+	// KEYCODE_WAKEUP
+	if _, err := adbClient.Shell(serial, "input", "keyevent", "224"); err != nil {
+		return err
+	}
+	// KEYCODE_MENU dismisses the swipe keyguard
+	if _, err := adbClient.Shell(serial, "input", "keyevent", "82"); err != nil {
+		return err
+	}
+	// available from API level 26, older devices are unlocked by the key event
+	if _, err := adbClient.Shell(serial, "wm", "dismiss-keyguard"); err != nil {
+		log.Debugf("Failed to dismiss keyguard: %s", err)
+	}
+	if _, err := adbClient.Shell(serial, "svc", "power", "stayon", "true"); err != nil {
+		return err
+	}
+
+	for i := 0; i < screenCheckAttempts; i++ {
+		out, err := adbClient.Shell(serial, "dumpsys", "power")
+		if err == nil && strings.Contains(out, "mWakefulness=Awake") {
+			return nil
+		}
+		time.Sleep(screenCheckInterval)
+	}
+	return fmt.Errorf("screen is not awake after %d checks", screenCheckAttempts)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// foldable returns whether the AVD has a hinge sensor, the device profiles of foldables enable it.
+func foldable(avdName string) (bool, error) {
+	pth, err := avdConfigPath(avdName)
+	if err != nil {
+		return false, err
+	}
+	cfg, err := readAVDConfig(pth)
+	if err != nil {
+		return false, fmt.Errorf("failed to read AVD config: %s", err)
+	}
+	hinge, _ := cfg.get("hw.sensor.hinge")
+	return hinge == "yes", nil
+}
+
+// setFoldPosture returns a post-boot action which sets the posture of the foldable device through the emulator console.
+func setFoldPosture(posture string) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(_ emulatormanager.ADB, serial string) error {
+		client, err := emuconsole.DialSerial(serial)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				log.Warnf("Failed to close emulator console connection: %s", err)
+			}
+		}()
+
+		avdName, err := client.AVDName()
+		if err != nil {
+			return err
+		}
+		isFoldable, err := foldable(avdName)
+		if err != nil {
+			return err
+		}
+		if !isFoldable {
+			return fmt.Errorf("%s is not a foldable device, use a foldable device profile (like 7.6in Foldable)", avdName)
+		}
+		return client.SetPosture(posture)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// requiresAcceleration returns whether the system images of the given ABI need hardware acceleration to run.
+// ARM images run accelerated only on ARM hosts, on other hosts they are translated.
+func requiresAcceleration(abi string, hostIsARM bool) bool {
+	if hostIsARM {
+		return abi == "arm64-v8a"
+	}
+	return strings.HasPrefix(abi, "x86")
+}
+
+// resolveABI returns the system image ABI to use on the host.
+// The auto ABI selects the ABI native to the host, x86 images are rejected on ARM hosts as they can't boot there.
+func resolveABI(abi string, hostIsARM bool) (string, error) {
+	if abi == "auto" {
+		if hostIsARM {
+			return "arm64-v8a", nil
+		}
+		return "x86_64", nil
+	}
+	if hostIsARM && strings.HasPrefix(abi, "x86") {
+		return "", fmt.Errorf("the %s system image can't run on this ARM (Apple Silicon) host, use the arm64-v8a or auto ABI instead", abi)
+	}
+	return abi, nil
+}
+
+// supportedABIs are the values accepted by the abi input.
+var supportedABIs = []string{"auto", "x86", "armeabi-v7a", "arm64-v8a", "x86_64"}
+
+// resolveABIs parses the comma or newline separated ABI list and resolves each ABI to use on the host.
+func resolveABIs(list string, hostIsARM bool) ([]string, error) {
+	var abis []string
+	seen := map[string]bool{}
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !containsString(supportedABIs, item) {
+			return nil, fmt.Errorf("unknown ABI (%s), supported ABIs: %s", item, strings.Join(supportedABIs, ", "))
+		}
+		abi, err := resolveABI(item, hostIsARM)
+		if err != nil {
+			return nil, err
+		}
+		if seen[abi] {
+			return nil, fmt.Errorf("ABI (%s) is listed multiple times", abi)
+		}
+		seen[abi] = true
+		abis = append(abis, abi)
+	}
+	return abis, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// accelerationHint returns an actionable remediation message for the host OS.
+func accelerationHint(goos string) string {
+	switch goos {
+	case "linux":
+		return "Make sure KVM is enabled: the kvm kernel module is loaded, /dev/kvm exists and the current user can read and write it (for example by adding the user to the kvm group). " +
+			"On a virtual machine, nested virtualization has to be enabled."
+	case "darwin":
+		return "Make sure the Hypervisor.framework is supported (sysctl kern.hv_support returns 1). On Apple Silicon machines use an arm64-v8a system image."
+	default:
+		return "Enable the hardware acceleration of the host, see: https://developer.android.com/studio/run/emulator-acceleration"
+	}
+}
+
+// checkHostAcceleration checks the OS level prerequisite of the emulator acceleration.
+func checkHostAcceleration(goos string) error {
+	switch goos {
+	case "linux":
+		kvm, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+		if os.IsNotExist(err) {
+			return missingKVMError()
+		}
+		if err != nil {
+			return fmt.Errorf("/dev/kvm is not accessible: %s", err)
+		}
+		return kvm.Close()
+	case "darwin":
+		out, err := command.New("sysctl", "-n", "kern.hv_support").RunAndReturnTrimmedCombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to check Hypervisor.framework support: %s, output: %s", err, out)
+		}
+		if out != "1" {
+			return fmt.Errorf("Hypervisor.framework is not supported (kern.hv_support: %s)", out)
+		}
+	}
+	return nil
+}
+
+// missingKVMError tells why /dev/kvm doesn't exist: on cloud VMs and in containers it is usually missing because
+// the (nested) virtualization extensions are not exposed to the guest, which makes the emulator hang until the timeout.
+func missingKVMError() error {
+	if cpuinfo, err := ioutil.ReadFile("/proc/cpuinfo"); err == nil && !virtualizationCPUFlags.Match(cpuinfo) {
+		return errors.New("nested virtualization is not available: /dev/kvm doesn't exist and the CPU doesn't report the VT-x/AMD-V extensions (no vmx or svm flag in /proc/cpuinfo). " +
+			"Enable nested virtualization for the VM (for example with --enable-nested-virtualization on Google Cloud, or use a bare metal instance on AWS), or run the build on a physical host")
+	}
+	if runningInContainer() {
+		return errors.New("/dev/kvm doesn't exist in the container: pass the device to the container (for example docker run --device /dev/kvm) and make sure KVM is enabled on the host")
+	}
+	return errors.New("/dev/kvm doesn't exist: load the kvm kernel module (modprobe kvm_intel or kvm_amd)")
+}
+
+// virtualizationCPUFlags matches the CPU flags of the Intel VT-x and AMD-V extensions in /proc/cpuinfo.
+var virtualizationCPUFlags = regexp.MustCompile(`(?m)^flags\s*:.*\b(vmx|svm)\b`)
+
+// runningInContainer tells if the step runs in a Docker or Kubernetes container.
+func runningInContainer() bool {
+	if exists, err := pathutil.IsPathExists("/.dockerenv"); err == nil && exists {
+		return true
+	}
+	cgroup, err := ioutil.ReadFile("/proc/1/cgroup")
+	return err == nil && (strings.Contains(string(cgroup), "docker") || strings.Contains(string(cgroup), "kubepods"))
+}
+
+// checkAcceleration verifies that the emulator can use hardware acceleration on this host.
+func checkAcceleration(emulatorPath string) error {
+	if err := checkHostAcceleration(runtime.GOOS); err != nil {
+		return fmt.Errorf("hardware acceleration is not available: %s\n%s", err, accelerationHint(runtime.GOOS))
+	}
+
+	cmd := command.New(emulatorPath, "-accel-check")
+	log.Donef("$ %s", cmd.PrintableCommandArgs())
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hardware acceleration is not available: %s\n%s", out, accelerationHint(runtime.GOOS))
+	}
+	log.Printf("%s", out)
+	return nil
+}
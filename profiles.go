@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// deviceProfiles returns the IDs of the device profiles known by avdmanager.
+func deviceProfiles(avdManagerPath string) ([]string, error) {
+	cmd := command.New(avdManagerPath, "list", "device", "-c")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+
+	var ids []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		// the compact list can be preceded by warnings and loading messages
+		if line == "" || strings.HasPrefix(line, "Warning:") || strings.HasPrefix(line, "Loading") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, nil
+}
+
+// validateDeviceProfile checks that the profile exists, so a typo fails the step before the system image download
+// instead of failing the AVD creation.
+func validateDeviceProfile(profile string, available []string) error {
+	var related []string
+	for _, id := range available {
+		if id == profile {
+			return nil
+		}
+		if strings.Contains(strings.ToLower(id), strings.ToLower(profile)) {
+			related = append(related, id)
+		}
+	}
+	if len(related) > 0 {
+		return fmt.Errorf("device profile (%s) not found, similar profiles: %s", profile, strings.Join(related, ", "))
+	}
+	return fmt.Errorf("device profile (%s) not found, available profiles: %s", profile, strings.Join(available, ", "))
+}
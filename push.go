@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// filePush is a local file or directory pushed to the given path of the device.
+type filePush struct {
+	localPath  string
+	devicePath string
+}
+
+// parseFilePushes parses the newline separated list of <local path>:<device path> pairs.
+func parseFilePushes(list string) ([]filePush, error) {
+	var pushes []filePush
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		separator := strings.LastIndex(line, ":")
+		if separator <= 0 || separator == len(line)-1 {
+			return nil, fmt.Errorf("invalid item (%s), expected format: <local path>:<device path>", line)
+		}
+		push := filePush{localPath: line[:separator], devicePath: line[separator+1:]}
+		if _, err := os.Stat(push.localPath); err != nil {
+			return nil, fmt.Errorf("local path (%s) is not accessible: %s", push.localPath, err)
+		}
+		if !strings.HasPrefix(push.devicePath, "/") {
+			return nil, fmt.Errorf("device path must be absolute: %s", push.devicePath)
+		}
+		pushes = append(pushes, push)
+	}
+	return pushes, nil
+}
+
+// pushFiles pushes the files to the device one by one and verifies their checksum on the device.
+// Directories are pushed recursively, keeping their layout under the device path.
+func pushFiles(adbClient emulatormanager.ADB, serial string, pushes []filePush) error {
+	for _, push := range pushes {
+		log.Printf("Pushing %s to %s (%s)", push.localPath, push.devicePath, serial)
+		err := filepath.Walk(push.localPath, func(pth string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(push.localPath, pth)
+			if err != nil {
+				return err
+			}
+			devicePath := push.devicePath
+			if rel != "." {
+				devicePath = path.Join(push.devicePath, filepath.ToSlash(rel))
+			}
+			return pushFile(adbClient, serial, pth, devicePath)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pushFile(adbClient emulatormanager.ADB, serial, localPath, devicePath string) error {
+	if _, err := adbClient.Run(serial, "push", localPath, devicePath); err != nil {
+		return err
+	}
+
+	localSum, err := fileMD5(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum of %s: %s", localPath, err)
+	}
+	out, err := adbClient.Shell(serial, "md5sum", devicePath)
+	if err != nil {
+		// md5sum is part of toybox, which is available from API level 23
+		log.Warnf("Couldn't verify checksum of %s: %s", devicePath, err)
+		return nil
+	}
+	if fields := strings.Fields(out); len(fields) == 0 || fields[0] != localSum {
+		return fmt.Errorf("checksum mismatch of %s, local: %s, device: %s", devicePath, localSum, out)
+	}
+	return nil
+}
+
+func fileMD5(pth string) (string, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
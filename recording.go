@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+	"github.com/kballard/go-shellquote"
+)
+
+// screenrecordSegmentSeconds is the maximum length of a single screenrecord run (limited to 3 minutes by Android).
+const screenrecordSegmentSeconds = 180
+
+// screenRecordingPath returns where the screen recording of the device is written in the deploy directory.
+func screenRecordingPath(deployDir, serial string) string {
+	return filepath.Join(deployDir, fmt.Sprintf("emulator-screenrecord-%s.h264", serial))
+}
+
+// startScreenRecording records the screen of the device into the file at path for the rest of the build.
+// screenrecord stops after 3 minutes, so it is restarted in a loop until the device goes away.
+// The raw H.264 segments are appended to the same file, which results in a single playable stream.
+func startScreenRecording(adbClient emulatormanager.ADB, serial, path string) (int, error) {
+	recordingFile, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	// the child process gets its own copy of the file descriptor
+	defer func() {
+		if err := recordingFile.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", path, err)
+		}
+	}()
+
+	screenrecord := shellquote.Join(adbClient.Path, "-s", serial, "exec-out",
+		"screenrecord", "--output-format=h264", fmt.Sprintf("--time-limit=%d", screenrecordSegmentSeconds), "-")
+	cmd := command.New("/bin/sh", "-c", fmt.Sprintf("while %s; do :; done", screenrecord)).SetStdout(recordingFile)
+	// the terminate mode stops the whole process group
+	emulatormanager.StartInProcessGroup(cmd.GetCmd())
+	if err := cmd.GetCmd().Start(); err != nil {
+		return 0, fmt.Errorf("failed to start screen recording: %s", err)
+	}
+
+	pid := cmd.GetCmd().Process.Pid
+	if err := cmd.GetCmd().Process.Release(); err != nil {
+		return pid, fmt.Errorf("failed to release screen recording process: %s", err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const resourceSampleInterval = time.Duration(5) * time.Second
+
+// resourceSample is the resource usage of the emulator process tree (the emulator launcher and its qemu children).
+type resourceSample struct {
+	at         time.Time
+	cpuPercent float64
+	rssKB      int
+}
+
+// resourceMonitor periodically samples the resource usage of the emulator process of a device.
+type resourceMonitor struct {
+	avdName string
+
+	mu      sync.Mutex
+	pid     int
+	samples []resourceSample
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func startResourceMonitor(avdName string) *resourceMonitor {
+	m := &resourceMonitor{avdName: avdName, stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+	go m.run()
+	return m
+}
+
+// track switches the monitor to the emulator process of a new boot attempt.
+func (m *resourceMonitor) track(pid int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pid = pid
+}
+
+func (m *resourceMonitor) run() {
+	defer close(m.doneCh)
+
+	ticker := time.NewTicker(resourceSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			pid := m.pid
+			m.mu.Unlock()
+			if pid == 0 {
+				continue
+			}
+
+			sample, err := sampleProcessTree(pid)
+			if err != nil {
+				log.Debugf("Failed to sample emulator process (%d): %s", pid, err)
+				continue
+			}
+			m.mu.Lock()
+			m.samples = append(m.samples, sample)
+			m.mu.Unlock()
+		}
+	}
+}
+
+// stop ends the sampling and returns the collected samples.
+func (m *resourceMonitor) stop() []resourceSample {
+	close(m.stopCh)
+	<-m.doneCh
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.samples
+}
+
+// sampleProcessTree sums the CPU and memory usage of the process and its descendants, as reported by ps.
+func sampleProcessTree(rootPID int) (resourceSample, error) {
+	cmd := command.New("ps", "-A", "-o", "pid=,ppid=,%cpu=,rss=")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return resourceSample{}, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+
+	type process struct {
+		ppid       int
+		cpuPercent float64
+		rssKB      int
+	}
+	processes := map[int]process{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		pid, pidErr := strconv.Atoi(fields[0])
+		ppid, ppidErr := strconv.Atoi(fields[1])
+		cpu, cpuErr := strconv.ParseFloat(fields[2], 64)
+		rss, rssErr := strconv.Atoi(fields[3])
+		if pidErr != nil || ppidErr != nil || cpuErr != nil || rssErr != nil {
+			continue
+		}
+		processes[pid] = process{ppid: ppid, cpuPercent: cpu, rssKB: rss}
+	}
+	if _, ok := processes[rootPID]; !ok {
+		return resourceSample{}, fmt.Errorf("process %d is not running", rootPID)
+	}
+
+	sample := resourceSample{at: time.Now()}
+	for pid, p := range processes {
+		// walk up the parents to find out if the process belongs to the tree
+		for ancestor := pid; ancestor > 1; ancestor = processes[ancestor].ppid {
+			if ancestor == rootPID {
+				sample.cpuPercent += p.cpuPercent
+				sample.rssKB += p.rssKB
+				break
+			}
+			if _, ok := processes[ancestor]; !ok {
+				break
+			}
+		}
+	}
+	return sample, nil
+}
+
+// findQEMUProcess returns the PID of the qemu process started by the emulator process.
+func findQEMUProcess(emulatorPID int) (int, error) {
+	cmd := command.New("ps", "-A", "-o", "pid=,ppid=,comm=")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("%s failed: %s, output: %s", cmd.PrintableCommandArgs(), err, out)
+	}
+
+	parents := map[int]int{}
+	var qemuPIDs []int
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, pidErr := strconv.Atoi(fields[0])
+		ppid, ppidErr := strconv.Atoi(fields[1])
+		if pidErr != nil || ppidErr != nil {
+			continue
+		}
+		parents[pid] = ppid
+		if strings.Contains(strings.Join(fields[2:], " "), "qemu-system") {
+			qemuPIDs = append(qemuPIDs, pid)
+		}
+	}
+
+	for _, pid := range qemuPIDs {
+		// the qemu process might be started through a wrapper, so every ancestor is checked
+		for ancestor := parents[pid]; ancestor > 1; ancestor = parents[ancestor] {
+			if ancestor == emulatorPID {
+				return pid, nil
+			}
+			if _, ok := parents[ancestor]; !ok {
+				break
+			}
+		}
+	}
+	return 0, fmt.Errorf("no qemu process found for emulator process %d", emulatorPID)
+}
+
+// printResourceSummary prints the average and peak usage of each emulator.
+func printResourceSummary(avdName string, samples []resourceSample) {
+	if len(samples) == 0 {
+		log.Printf("- %s: no resource samples collected", avdName)
+		return
+	}
+
+	var cpuSum, cpuPeak float64
+	var rssPeak int
+	for _, sample := range samples {
+		cpuSum += sample.cpuPercent
+		if sample.cpuPercent > cpuPeak {
+			cpuPeak = sample.cpuPercent
+		}
+		if sample.rssKB > rssPeak {
+			rssPeak = sample.rssKB
+		}
+	}
+	log.Printf("- %s: CPU average %.1f%%, peak %.1f%%; memory (RSS) peak %d MB (%d samples)",
+		avdName, cpuSum/float64(len(samples)), cpuPeak, rssPeak/1024, len(samples))
+}
+
+// writeResourceCSV writes the samples into the deploy directory and returns the file path.
+func writeResourceCSV(deployDir, avdName string, samples []resourceSample) (string, error) {
+	var b strings.Builder
+	b.WriteString("timestamp,cpu_percent,rss_kb\n")
+	for _, sample := range samples {
+		b.WriteString(fmt.Sprintf("%s,%.1f,%d\n", sample.at.UTC().Format(time.RFC3339), sample.cpuPercent, sample.rssKB))
+	}
+
+	pth := filepath.Join(deployDir, fmt.Sprintf("emulator-resources-%s.csv", avdName))
+	return pth, ioutil.WriteFile(pth, []byte(b.String()), 0644)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// bootResult describes a booted emulator, it is exported as the step's JSON result.
+type bootResult struct {
+	Serial              string       `json:"serial"`
+	ConsolePort         int          `json:"console_port"`
+	AVDName             string       `json:"avd_name"`
+	ABI                 string       `json:"abi"`
+	APILevel            int          `json:"api_level"`
+	Profile             string       `json:"profile"`
+	BootDurationSeconds float64      `json:"boot_duration_seconds"`
+	Retries             int          `json:"retries"`
+	PID                 int          `json:"pid"`
+	QEMUPID             int          `json:"qemu_pid,omitempty"`
+	GRPCPort            int          `json:"grpc_port,omitempty"`
+	Args                []string     `json:"emulator_args"`
+	Timings             timingReport `json:"timings"`
+
+	timings bootTimings
+}
+
+// newBootResult returns the result of the booted device, the boot duration and the ABI are set by the caller.
+func newBootResult(device emulatormanager.Device) bootResult {
+	return bootResult{
+		Serial:      device.Serial,
+		ConsolePort: device.ConsolePort,
+		AVDName:     device.AVDName,
+		Retries:     device.Retries,
+		PID:         device.PID,
+		Args:        device.Args,
+		timings: bootTimings{
+			processStarted: device.ProcessStarted,
+			deviceDetected: device.DeviceDetected,
+		},
+	}
+}
+
+// stepResult is the machine-readable summary of the step run.
+type stepResult struct {
+	Devices []bootResult `json:"devices"`
+}
+
+// resultFileName is the name of the JSON result file in the deploy directory.
+const resultFileName = "emulator-result.json"
+
+// writeResult writes the JSON result into the deploy directory and returns its content.
+func writeResult(deployDir string, result stepResult) (string, error) {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if deployDir != "" {
+		if err := ioutil.WriteFile(filepath.Join(deployDir, resultFileName), content, 0644); err != nil {
+			return "", err
+		}
+	}
+	return string(content), nil
+}
+
+// exportResults exports the serials, the ports and the PIDs of the devices and the JSON result as step outputs.
+// The per device outputs are suffixed with the index of the device when multiple ones are booted.
+func exportResults(deployDir string, results []bootResult) {
+	var serials []string
+	for _, result := range results {
+		serials = append(serials, result.Serial)
+	}
+
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_SERIAL", serials[0]); err != nil {
+		log.Warnf("Failed to export environment (BITRISE_EMULATOR_SERIAL), error: %s", err)
+	}
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_SERIALS", strings.Join(serials, ",")); err != nil {
+		log.Warnf("Failed to export environment (BITRISE_EMULATOR_SERIALS), error: %s", err)
+	}
+	if len(serials) > 1 {
+		for i, serial := range serials {
+			key := fmt.Sprintf("BITRISE_EMULATOR_SERIAL_%d", i+1)
+			if err := tools.ExportEnvironmentWithEnvman(key, serial); err != nil {
+				log.Warnf("Failed to export environment (%s), error: %s", key, err)
+			}
+		}
+	}
+	exportDevice := func(suffix string, result bootResult) {
+		envs := map[string]int{
+			"BITRISE_EMULATOR_CONSOLE_PORT" + suffix: result.ConsolePort,
+			// the adb port of an emulator is always its console port + 1
+			"BITRISE_EMULATOR_ADB_PORT" + suffix: result.ConsolePort + 1,
+			"BITRISE_EMULATOR_PID" + suffix:      result.PID,
+		}
+		if result.QEMUPID != 0 {
+			envs["BITRISE_EMULATOR_QEMU_PID"+suffix] = result.QEMUPID
+		}
+		if result.GRPCPort != 0 {
+			envs["BITRISE_EMULATOR_GRPC_PORT"+suffix] = result.GRPCPort
+		}
+		for key, value := range envs {
+			if err := tools.ExportEnvironmentWithEnvman(key, strconv.Itoa(value)); err != nil {
+				log.Warnf("Failed to export environment (%s), error: %s", key, err)
+			}
+		}
+	}
+	exportDevice("", results[0])
+	if len(results) > 1 {
+		for i, result := range results {
+			exportDevice(fmt.Sprintf("_%d", i+1), result)
+		}
+	}
+
+	resultJSON, err := writeResult(deployDir, stepResult{Devices: results})
+	if err != nil {
+		log.Warnf("Failed to write result file: %s", err)
+	} else if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_RESULT", resultJSON); err != nil {
+		log.Warnf("Failed to export environment (BITRISE_EMULATOR_RESULT), error: %s", err)
+	}
+}
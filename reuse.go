@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+	"github.com/bitrise-steplib/steps-avd-manager/emugrpc"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// parseEmuAVDName returns the AVD name from the output of the avd name console command: the name followed by OK.
+func parseEmuAVDName(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" && line != "OK" {
+			return line
+		}
+	}
+	return ""
+}
+
+// runningAVDs returns the serials of the online emulators mapped by their AVD name.
+func runningAVDs(adbClient emulatormanager.ADB, runningDevices map[string]string) map[string]string {
+	serials := map[string]string{}
+	for serial, state := range runningDevices {
+		if state != "device" {
+			log.Printf("- %s is %s, it can't be reused", serial, state)
+			continue
+		}
+		out, err := adbClient.Run(serial, "emu", "avd", "name")
+		if err != nil {
+			log.Warnf("Failed to query the AVD name of %s: %s", serial, err)
+			continue
+		}
+		if name := parseEmuAVDName(out); name != "" {
+			serials[name] = serial
+		}
+	}
+	return serials
+}
+
+// reuseDevice returns the already running emulator as a booted device, once it passes the readiness checks
+// (the boot completion if the boot is waited for).
+func reuseDevice(ctx context.Context, adbClient emulatormanager.ADB, avdName, serial string, boot *emulatormanager.BootCompletion) (emulatormanager.Device, error) {
+	now := time.Now()
+	device := emulatormanager.Device{Serial: serial, AVDName: avdName, ProcessStarted: now, DeviceDetected: now}
+	port, err := emuconsole.PortFromSerial(serial)
+	if err != nil {
+		return emulatormanager.Device{}, err
+	}
+	device.ConsolePort = port
+	if pid, err := emugrpc.ProcessID(port); err != nil {
+		log.Warnf("Failed to find the process of %s: %s", serial, err)
+	} else {
+		device.PID = pid
+	}
+
+	if boot != nil {
+		if err := emulatormanager.WaitForBootComplete(ctx, adbClient, serial, *boot); err != nil {
+			return emulatormanager.Device{}, fmt.Errorf("the running emulator (%s) is not ready: %s", serial, err)
+		}
+	}
+	return device, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+const (
+	adbdRestartTimeout = time.Duration(1) * time.Minute
+
+	rootAccessAttempts      = 3
+	rootAccessRetryInterval = time.Duration(5) * time.Second
+
+	writeCheckPath = "/system/.bitrise_write_check"
+)
+
+// enableRootAccess restarts adbd as root and remounts the system partition as writable, verifying both.
+// The steps are retried, as adbd and the remount are known to be flaky right after the boot.
+func enableRootAccess(ctx context.Context, adbClient emulatormanager.ADB, serial string, boot emulatormanager.BootCompletion) error {
+	var err error
+	for attempt := 1; attempt <= rootAccessAttempts; attempt++ {
+		if err = remountSystem(ctx, adbClient, serial, boot); err == nil {
+			if err = verifyRootAccess(adbClient, serial); err == nil {
+				return nil
+			}
+		}
+		if attempt < rootAccessAttempts {
+			log.Warnf("Attempt %d to enable root access failed: %s", attempt, err)
+			time.Sleep(rootAccessRetryInterval)
+		}
+	}
+	return fmt.Errorf("failed to enable root access after %d attempts: %s", rootAccessAttempts, err)
+}
+
+// verifyRootAccess checks that adbd runs as root and the system partition is writable.
+func verifyRootAccess(adbClient emulatormanager.ADB, serial string) error {
+	out, err := adbClient.Shell(serial, "id", "-u")
+	if err != nil {
+		return err
+	}
+	if out != "0" {
+		return fmt.Errorf("adbd is not running as root, uid: %s", out)
+	}
+	if _, err := adbClient.Shell(serial, "touch", writeCheckPath); err != nil {
+		return fmt.Errorf("system partition is not writable: %s", err)
+	}
+	_, err = adbClient.Shell(serial, "rm", writeCheckPath)
+	return err
+}
+
+// restartAsRoot restarts adbd on the device with root permissions and waits for the device to reconnect.
+func restartAsRoot(adbClient emulatormanager.ADB, serial string) error {
+	out, err := adbClient.Run(serial, "root")
+	if err != nil {
+		return err
+	}
+	// production builds (google_apis_playstore images) can't run adbd as root
+	if strings.Contains(out, "cannot run as root") {
+		return fmt.Errorf("adbd cannot run as root on this image: %s", out)
+	}
+	_, err = adbClient.RunWithTimeout(serial, adbdRestartTimeout, "wait-for-device")
+	return err
+}
+
+// remountSystem makes the system partition writable, the emulator has to be started with the -writable-system flag.
+// From API level 29 verified boot has to be disabled first, which only takes effect after a reboot.
+func remountSystem(ctx context.Context, adbClient emulatormanager.ADB, serial string, boot emulatormanager.BootCompletion) error {
+	if err := restartAsRoot(adbClient, serial); err != nil {
+		return err
+	}
+	out, err := adbClient.Run(serial, "remount")
+	if err == nil && !strings.Contains(out, "reboot") {
+		return nil
+	}
+
+	log.Printf("Disabling verified boot (%s)", serial)
+	if _, err := adbClient.Run(serial, "disable-verity"); err != nil {
+		return err
+	}
+	if err := rebootDevice(ctx, adbClient, serial, boot); err != nil {
+		return err
+	}
+	if err := restartAsRoot(adbClient, serial); err != nil {
+		return err
+	}
+	_, err = adbClient.Run(serial, "remount")
+	return err
+}
+
+// rebootDevice reboots the device and waits until it completes the boot again.
+func rebootDevice(ctx context.Context, adbClient emulatormanager.ADB, serial string, boot emulatormanager.BootCompletion) error {
+	log.Printf("Rebooting device (%s)", serial)
+	if _, err := adbClient.Run(serial, "reboot"); err != nil {
+		return err
+	}
+	// without waiting for the disconnect the boot check could still see the properties of the previous boot
+	if _, err := adbClient.RunWithTimeout(serial, adbdRestartTimeout, "wait-for-disconnect"); err != nil {
+		log.Warnf("Device (%s) did not disconnect: %s", serial, err)
+	}
+	if _, err := adbClient.RunWithTimeout(serial, boot.Timeout, "wait-for-device"); err != nil {
+		return err
+	}
+	return emulatormanager.WaitForBootComplete(ctx, adbClient, serial, boot)
+}
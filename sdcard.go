@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// sdcardSizePattern matches the SD card sizes accepted by avdmanager, like 512M or 2G (at least 9M).
+var sdcardSizePattern = regexp.MustCompile(`^[0-9]+[KMG]$`)
+
+// sdcardOption tells whether the sdcard input is a size the SD card is created with, or an existing SD card image.
+func sdcardOption(sdcard string) (size string, image string, err error) {
+	if sdcard == "" {
+		return "", "", nil
+	}
+	if sdcardSizePattern.MatchString(sdcard) {
+		return sdcard, "", nil
+	}
+	info, err := os.Stat(sdcard)
+	if err != nil {
+		return "", "", fmt.Errorf("%s is neither an SD card size (like 512M) nor an accessible SD card image: %s", sdcard, err)
+	}
+	if info.IsDir() {
+		return "", "", fmt.Errorf("SD card image is a directory: %s", sdcard)
+	}
+	return "", sdcard, nil
+}
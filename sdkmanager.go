@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// cmdlineTool returns the path of a command-line tool (like sdkmanager), the tools are batch files on Windows.
+func cmdlineTool(cmdlineToolsPath, name string) string {
+	if runtime.GOOS == "windows" {
+		name += ".bat"
+	}
+	return filepath.Join(cmdlineToolsPath, name)
+}
+
+// systemImageDir returns the SDK directory the system image of the AVD is installed to.
+func systemImageDir(androidHome string, spec avdSpec) string {
+	return filepath.Join(androidHome, "system-images", fmt.Sprintf("android-%d", spec.apiLevel), spec.tag, spec.abi)
+}
+
+// systemImageInstalled checks whether the system image backing the AVD is present in the SDK.
+func systemImageInstalled(androidHome string, spec avdSpec) (bool, error) {
+	return pathutil.IsPathExists(filepath.Join(systemImageDir(androidHome, spec), "source.properties"))
+}
+
+// installPackageCommand returns the sdkmanager command installing or updating the given package, it is killed once ctx is done.
+// The command output is streamed to the log, so the download progress is visible.
+func installPackageCommand(ctx context.Context, sdkManagerPath, channel, pkg string) *command.Model {
+	return command.NewWithCmd(exec.CommandContext(ctx, sdkManagerPath, "--verbose", "--channel="+channel, pkg)).
+		SetStdin(yesReader{}). // hitting yes in case it waits for accepting license
+		SetStdout(os.Stdout).
+		SetStderr(os.Stderr)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// sessionFileName is the name of the session file in the temporary directory, unless exported by a previous run.
+// The file of a Bitrise build is suffixed with the build slug, so parallel builds of a runner don't share it.
+const sessionFileName = "emulator-session"
+
+// session records what the step started, so the step in terminate mode can tear it down.
+// Every boot run of the build appends its devices and processes to the same session,
+// the first boot run of a build drops what a previous build (which didn't terminate) left in the file.
+type session struct {
+	// BuildSlug is the Bitrise build which started the session, empty outside Bitrise
+	BuildSlug string          `json:"build_slug"`
+	Devices   []sessionDevice `json:"devices"`
+	// Processes are the detached helper processes attached to the devices (logcat, screen recording, health monitor)
+	Processes []sessionProcess `json:"processes"`
+}
+
+type sessionDevice struct {
+	AVDName string `json:"avd_name"`
+	Serial  string `json:"serial"`
+	PID     int    `json:"pid"`
+	// StartTime is the start time of the emulator process, a PID reused by another process has a different one
+	StartTime string `json:"start_time,omitempty"`
+	// DeleteAVD is set for the AVDs created by the step which are not kept for caching
+	DeleteAVD bool `json:"delete_avd"`
+	// CacheArchive is the AVD cache archive written once the emulator is stopped, empty if the AVD cache is disabled
+	CacheArchive string `json:"cache_archive,omitempty"`
+}
+
+type sessionProcess struct {
+	Name   string `json:"name"`
+	Serial string `json:"serial"`
+	PID    int    `json:"pid"`
+	// PGID is the process group of the helper, which contains its child processes too (adb, screenrecord)
+	PGID int `json:"pgid"`
+	// StartTime is the start time of the helper process, a PID reused by another process has a different one
+	StartTime string `json:"start_time,omitempty"`
+}
+
+// sessionFilePath returns the path of the session file, exported as BITRISE_EMULATOR_SESSION_FILE.
+func sessionFilePath() string {
+	if pth := os.Getenv("BITRISE_EMULATOR_SESSION_FILE"); pth != "" {
+		return pth
+	}
+	if slug := os.Getenv("BITRISE_BUILD_SLUG"); slug != "" {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s.json", sessionFileName, slug))
+	}
+	return filepath.Join(os.TempDir(), sessionFileName+".json")
+}
+
+// sessionStarted is set once the current run wrote the session file.
+var sessionStarted bool
+
+// isFirstBootRun tells if no previous boot run of the build recorded a session, the path is exported by the first one.
+func isFirstBootRun() bool {
+	return !sessionStarted && os.Getenv("BITRISE_EMULATOR_SESSION_FILE") == ""
+}
+
+// loadSession reads the session file, a missing file is an empty session.
+func loadSession(pth string) (session, error) {
+	content, err := ioutil.ReadFile(pth)
+	if os.IsNotExist(err) {
+		return session{}, nil
+	} else if err != nil {
+		return session{}, err
+	}
+	var s session
+	if err := json.Unmarshal(content, &s); err != nil {
+		return session{}, err
+	}
+	return s, nil
+}
+
+// appendSession adds the devices and processes to the session file. A fresh session replaces the content
+// left by a previous build, whose PIDs might have been reused since then and whose AVDs are not ours to delete.
+func appendSession(pth, buildSlug string, fresh bool, devices []sessionDevice, processes []sessionProcess) error {
+	s, err := loadSession(pth)
+	if err != nil {
+		return err
+	}
+	if fresh || s.BuildSlug != buildSlug {
+		if len(s.Devices) > 0 || len(s.Processes) > 0 {
+			log.Warnf("Dropping the session of a previous build from %s, its emulators were not terminated", pth)
+		}
+		s = session{BuildSlug: buildSlug}
+	}
+	s.Devices = append(s.Devices, devices...)
+	s.Processes = append(s.Processes, processes...)
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pth, content, 0644)
+}
+
+// recordSession appends the devices and processes to the session file and exports its path for the terminate mode.
+// The teardown is best effort, so failures are only logged.
+func recordSession(devices []sessionDevice, processes []sessionProcess) {
+	for i := range devices {
+		devices[i].StartTime = recordedStartTime(devices[i].PID)
+	}
+	for i := range processes {
+		processes[i].StartTime = recordedStartTime(processes[i].PID)
+	}
+
+	pth := sessionFilePath()
+	if err := appendSession(pth, os.Getenv("BITRISE_BUILD_SLUG"), isFirstBootRun(), devices, processes); err != nil {
+		log.Warnf("Failed to write session file: %s", err)
+		return
+	}
+	sessionStarted = true
+	if err := tools.ExportEnvironmentWithEnvman("BITRISE_EMULATOR_SESSION_FILE", pth); err != nil {
+		log.Warnf("Failed to export environment (BITRISE_EMULATOR_SESSION_FILE), error: %s", err)
+	}
+}
+
+// recordedStartTime returns the start time of the process for the session, empty if it can't be queried.
+// Without a start time the terminate mode doesn't signal the process.
+func recordedStartTime(pid int) string {
+	if pid == 0 {
+		return ""
+	}
+	startTime, err := emulatormanager.ProcessStartTime(pid)
+	if err != nil {
+		log.Warnf("Failed to record the start time of process (%d): %s", pid, err)
+	}
+	return startTime
+}
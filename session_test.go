@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAppendSession(t *testing.T) {
+	previous := []sessionDevice{{AVDName: "previous", Serial: "emulator-5554", PID: 42}}
+	current := []sessionDevice{{AVDName: "current", Serial: "emulator-5556", PID: 43}}
+	tests := []struct {
+		name      string
+		prevSlug  string
+		buildSlug string
+		fresh     bool
+		want      []sessionDevice
+	}{
+		{name: "later boot run of the build", prevSlug: "build-1", buildSlug: "build-1", want: append(append([]sessionDevice{}, previous...), current...)},
+		{name: "first boot run of the build", prevSlug: "build-1", buildSlug: "build-1", fresh: true, want: current},
+		{name: "session of another build", prevSlug: "build-1", buildSlug: "build-2", want: current},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pth := filepath.Join(t.TempDir(), "session.json")
+			if err := appendSession(pth, tt.prevSlug, true, previous, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := appendSession(pth, tt.buildSlug, tt.fresh, current, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := loadSession(pth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.BuildSlug != tt.buildSlug {
+				t.Errorf("build slug = %s, want %s", s.BuildSlug, tt.buildSlug)
+			}
+			if !reflect.DeepEqual(s.Devices, tt.want) {
+				t.Errorf("devices = %+v, want %+v", s.Devices, tt.want)
+			}
+		})
+	}
+}
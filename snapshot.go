@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// defaultSnapshotName is the snapshot the emulator loads on Quick Boot if no other snapshot is requested.
+const defaultSnapshotName = "default_boot"
+
+// saveSnapshot saves the current state of the running emulator as a Quick Boot snapshot.
+func saveSnapshot(adbClient emulatormanager.ADB, serial, name string) error {
+	out, err := adbClient.Run(serial, "emu", "avd", "snapshot", "save", name)
+	if err != nil {
+		return err
+	}
+	if out != "OK" {
+		return fmt.Errorf("unexpected emulator console response: %s", out)
+	}
+	return nil
+}
+
+// cacheAVD marks the AVD directory (including its snapshots) to be saved by the Bitrise Cache:Push step.
+func cacheAVD(name string) error {
+	dir, err := emulatormanager.AVDHome()
+	if err != nil {
+		return fmt.Errorf("failed to locate the AVD home: %s", err)
+	}
+
+	avdCache := cache.New()
+	avdCache.IncludePath(filepath.Join(dir, name+".avd"), filepath.Join(dir, name+".ini"))
+	// lock files of the running emulator must not be restored in the next build
+	avdCache.ExcludePath(filepath.Join(dir, name+".avd", "**", "*.lock"))
+	return avdCache.Commit()
+}
+
+// cacheSnapshots saves the Quick Boot snapshot of the booted devices and marks their AVDs for caching.
+// The snapshot is saved through gRPC if the emulator is controlled by it. The failures are reported as warnings.
+func cacheSnapshots(ctx context.Context, adbClient emulatormanager.ADB, snapshotName string, avds []avdSpec, results []bootResult) {
+	fmt.Println()
+	log.Infof("Caching Quick Boot snapshot")
+	if snapshotName == "" {
+		snapshotName = defaultSnapshotName
+	}
+	for i, avd := range avds {
+		var err error
+		if results[i].GRPCPort != 0 {
+			err = saveSnapshotGRPC(ctx, results[i].PID, snapshotName)
+		} else {
+			err = saveSnapshot(adbClient, results[i].Serial, snapshotName)
+		}
+		if err != nil {
+			log.Warnf("Failed to save snapshot (%s) of %s: %s", snapshotName, avd.name, err)
+		} else if err := cacheAVD(avd.name); err != nil {
+			log.Warnf("Failed to mark AVD (%s) for caching: %s", avd.name, err)
+		} else {
+			log.Printf("- Snapshot (%s) of %s saved, the AVD will be cached by the Cache:Push step", snapshotName, avd.name)
+		}
+	}
+}
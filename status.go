@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// Step states of the status file.
+const (
+	statusStarting = "starting"
+	statusReady    = "ready"
+	statusFailed   = "failed"
+)
+
+// deviceStates maps the lifecycle events to the device states of the status file, other events don't change the state.
+var deviceStates = map[string]string{
+	emulatormanager.EventProcessStarted:   "started",
+	emulatormanager.EventDeviceDetected:   "device_detected",
+	emulatormanager.EventRestartTriggered: "restarting",
+	emulatormanager.EventBootCompleted:    "booted",
+	emulatormanager.EventBootFailed:       "failed",
+	eventDeviceReady:                      "ready",
+}
+
+type stepStatus struct {
+	State     string         `json:"state"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Devices   []deviceStatus `json:"devices"`
+}
+
+type deviceStatus struct {
+	AVDName   string    `json:"avd_name"`
+	Serial    string    `json:"serial,omitempty"`
+	State     string    `json:"state"`
+	Attempt   int       `json:"attempt,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// statusFile keeps the current state of the step and its devices in a JSON file, so parallel steps and dashboards
+// can poll the readiness of the emulators without parsing the build log. The file is replaced atomically on every change.
+type statusFile struct {
+	mu     sync.Mutex
+	path   string
+	status stepStatus
+}
+
+// status is set if the status file is enabled, failf reports the failure in it.
+var status *statusFile
+
+func newStatusFile(pth string, avdNames []string) (*statusFile, error) {
+	now := time.Now()
+	f := &statusFile{path: pth, status: stepStatus{State: statusStarting, UpdatedAt: now}}
+	for _, name := range avdNames {
+		f.status.Devices = append(f.status.Devices, deviceStatus{AVDName: name, State: "pending", UpdatedAt: now})
+	}
+	return f, f.write()
+}
+
+// update applies the lifecycle event to the state of its device.
+func (f *statusFile) update(event emulatormanager.Event) {
+	if f == nil {
+		return
+	}
+	state, ok := deviceStates[event.Type]
+	if !ok {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.status.Devices {
+		device := &f.status.Devices[i]
+		if device.AVDName != event.AVDName {
+			continue
+		}
+		device.State, device.UpdatedAt = state, event.Time
+		if event.Serial != "" {
+			device.Serial = event.Serial
+		}
+		if event.Attempt != 0 {
+			device.Attempt = event.Attempt
+		}
+	}
+	f.status.UpdatedAt = event.Time
+	f.writeLocked()
+}
+
+// finish sets the state of the step: ready or failed with the error.
+func (f *statusFile) finish(state, errorMessage string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status.State, f.status.Error, f.status.UpdatedAt = state, errorMessage, time.Now()
+	f.writeLocked()
+}
+
+func (f *statusFile) writeLocked() {
+	if err := f.write(); err != nil {
+		log.Warnf("Failed to write status file: %s", err)
+	}
+}
+
+// write replaces the status file through a temporary file, so readers never see a partially written status.
+func (f *statusFile) write() error {
+	content, err := json.MarshalIndent(f.status, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, f.path)
+}
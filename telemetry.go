@@ -0,0 +1,37 @@
+package main
+
+import "github.com/bitrise-io/go-utils/log"
+
+const (
+	noMetricsFlag         = "-no-metrics"
+	metricsCollectionFlag = "-metrics-collection"
+)
+
+// enforceMetricsOptOut returns the custom flags with -no-metrics appended and -metrics-collection removed, so the emulator
+// never reports usage statistics. They are custom flags, as the default flags can be disabled by the user.
+func enforceMetricsOptOut(customFlags []string) []string {
+	var flags []string
+	for _, flag := range customFlags {
+		switch flag {
+		case metricsCollectionFlag:
+			log.Warnf("Dropping %s from the start command flags, metrics collection is disabled", flag)
+		case noMetricsFlag:
+		default:
+			flags = append(flags, flag)
+		}
+	}
+	return append(flags, noMetricsFlag)
+}
+
+// metricsStatus describes whether the emulator reports usage statistics with the given custom flags.
+func metricsStatus(customFlags []string) string {
+	for _, flag := range customFlags {
+		switch flag {
+		case noMetricsFlag:
+			return "disabled (" + noMetricsFlag + ")"
+		case metricsCollectionFlag:
+			return "enabled (" + metricsCollectionFlag + ")"
+		}
+	}
+	return "emulator default"
+}
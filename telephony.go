@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emuconsole"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// phoneNumberPattern matches the phone numbers accepted by the sms and gsm console commands.
+var phoneNumberPattern = regexp.MustCompile(`^\+?[0-9]+$`)
+
+// telephonyEvent is an incoming SMS or call simulated through the emulator console.
+type telephonyEvent struct {
+	// kind is sms or call
+	kind   string
+	number string
+	text   string
+}
+
+// parseTelephonyEvents parses the newline separated event list, an event is either sms <number> <text> or call <number>.
+func parseTelephonyEvents(list string) ([]telephonyEvent, error) {
+	var events []telephonyEvent
+	for _, line := range strings.Split(list, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		event := telephonyEvent{kind: fields[0]}
+		switch {
+		case event.kind == "sms" && len(fields) >= 3:
+			event.number = fields[1]
+			event.text = strings.Join(fields[2:], " ")
+		case event.kind == "call" && len(fields) == 2:
+			event.number = fields[1]
+		default:
+			return nil, fmt.Errorf("invalid event (%s), expected format: sms <number> <text> or call <number>", strings.TrimSpace(line))
+		}
+		if !phoneNumberPattern.MatchString(event.number) {
+			return nil, fmt.Errorf("invalid phone number (%s) in event: %s", event.number, strings.TrimSpace(line))
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// sendTelephonyEvents returns a post-boot action which triggers the events in order through the emulator console.
+func sendTelephonyEvents(events []telephonyEvent) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(_ emulatormanager.ADB, serial string) error {
+		client, err := emuconsole.DialSerial(serial)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				log.Warnf("Failed to close emulator console connection: %s", err)
+			}
+		}()
+
+		for _, event := range events {
+			if event.kind == "sms" {
+				err = client.SendSMS(event.number, event.text)
+			} else {
+				err = client.GSMCall(event.number)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
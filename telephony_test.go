@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTelephonyEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []telephonyEvent
+		wantErr bool
+	}{
+		{name: "empty list"},
+		{
+			name: "sms and call",
+			list: "sms +36301234567 Your code is 1234\ncall 5551234",
+			want: []telephonyEvent{
+				{kind: "sms", number: "+36301234567", text: "Your code is 1234"},
+				{kind: "call", number: "5551234"},
+			},
+		},
+		{
+			name: "blank lines and extra spaces",
+			list: "\n  sms 123   hello   world  \n\n",
+			want: []telephonyEvent{{kind: "sms", number: "123", text: "hello world"}},
+		},
+		{name: "sms without text", list: "sms 123", wantErr: true},
+		{name: "call with text", list: "call 123 hello", wantErr: true},
+		{name: "unknown event", list: "mms 123 hello", wantErr: true},
+		{name: "invalid phone number", list: "call 555-1234", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTelephonyEvents(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTelephonyEvents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTelephonyEvents() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
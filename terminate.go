@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// terminateSession stops the helper processes and the emulators of the session, archives the cached AVDs,
+// deletes the temporary AVDs and removes the session file. The helpers are stopped first, so the logcat and screen recording files are complete
+// and the health monitor doesn't report the intentional shutdown as a dead device.
+func terminateSession(adbClient emulatormanager.ADB, pth string) error {
+	s, err := loadSession(pth)
+	if err != nil {
+		return fmt.Errorf("failed to read session file (%s): %s", pth, err)
+	}
+	if len(s.Devices) == 0 && len(s.Processes) == 0 {
+		log.Printf("No emulator was started by the step")
+		return nil
+	}
+	if buildSlug := os.Getenv("BITRISE_BUILD_SLUG"); s.BuildSlug != buildSlug {
+		log.Warnf("The session file (%s) was written by another build (%s), its emulators are not terminated", pth, s.BuildSlug)
+		return nil
+	}
+
+	var failures []string
+	for _, p := range s.Processes {
+		log.Printf("Stopping %s of %s (%d)", p.Name, p.Serial, p.PID)
+		if !processUnchanged(p.PID, p.StartTime) {
+			log.Printf("- The process is already gone")
+			continue
+		}
+		if err := emulatormanager.StopProcessGroup(p.PGID); err != nil {
+			log.Warnf("Failed to stop %s of %s: %s", p.Name, p.Serial, err)
+		}
+	}
+
+	// without a known process the shutdown is not waited for
+	stopped := make([]bool, len(s.Devices))
+	for i, device := range s.Devices {
+		log.Printf("Shutting down %s (%s)", device.Serial, device.AVDName)
+		var err error
+		if stopped[i], err = shutdownSessionDevice(adbClient, device); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", device.Serial, err))
+		}
+	}
+
+	for i, device := range s.Devices {
+		if device.CacheArchive == "" {
+			continue
+		}
+		if !stopped[i] {
+			log.Warnf("Not caching AVD (%s), its emulator might still be running", device.AVDName)
+			continue
+		}
+		log.Printf("Caching AVD (%s)", device.AVDName)
+		if err := saveAVDCache(device.CacheArchive, device.AVDName); err != nil {
+			log.Warnf("Failed to cache AVD (%s): %s", device.AVDName, err)
+		} else {
+			log.Printf("- AVD (%s) archived to %s", device.AVDName, device.CacheArchive)
+		}
+	}
+
+	for _, device := range s.Devices {
+		if !device.DeleteAVD {
+			continue
+		}
+		log.Printf("Deleting AVD (%s)", device.AVDName)
+		if err := deleteAVD(device.AVDName); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: failed to delete AVD: %s", device.AVDName, err))
+		}
+	}
+
+	if err := os.Remove(pth); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove session file: %s", err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// shutdownSessionDevice stops the emulator gracefully, the same way as a failed boot attempt, and tells if it is stopped.
+// Without a known process only the adb emu kill command can be sent, which is not waited for.
+func shutdownSessionDevice(adbClient emulatormanager.ADB, device sessionDevice) (bool, error) {
+	if device.PID == 0 || device.StartTime == "" {
+		_, err := adbClient.Run(device.Serial, "emu", "kill")
+		return false, err
+	}
+	process, err := os.FindProcess(device.PID)
+	if err != nil || !processUnchanged(device.PID, device.StartTime) {
+		log.Printf("- The emulator process (%d) is already gone", device.PID)
+		return true, nil
+	}
+	err = emulatormanager.ShutdownEmulator(adbClient, device.Serial, process, emulatormanager.ProcessExitWaiter(process))
+	return err == nil, err
+}
+
+// processUnchanged tells if the PID still belongs to the process recorded in the session,
+// the PID of an exited process can be reused by an unrelated one.
+func processUnchanged(pid int, startTime string) bool {
+	if startTime == "" {
+		return false
+	}
+	current, err := emulatormanager.ProcessStartTime(pid)
+	return err == nil && current == startTime
+}
+
+// deleteAVD removes the AVD from the AVD home.
+func deleteAVD(name string) error {
+	dir, err := emulatormanager.AVDHome()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, name+".avd")); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name+".ini")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
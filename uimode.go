@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// parseFontScale parses the font scale input, an empty value leaves the font scale unchanged (0).
+func parseFontScale(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	scale, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || scale <= 0 || scale > 3 {
+		return 0, fmt.Errorf("invalid font scale (%s), expected a number between 0 and 3, like 1.3", s)
+	}
+	return scale, nil
+}
+
+// setNightMode returns a post-boot action which turns the dark theme on or off.
+func setNightMode(enabled bool) func(adbClient emulatormanager.ADB, serial string) error {
+	mode := "no"
+	if enabled {
+		mode = "yes"
+	}
+	return func(adbClient emulatormanager.ADB, serial string) error {
+		_, err := adbClient.Shell(serial, "cmd", "uimode", "night", mode)
+		return err
+	}
+}
+
+// setFontScale returns a post-boot action which sets the system font scale.
+func setFontScale(scale float64) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(adbClient emulatormanager.ADB, serial string) error {
+		_, err := adbClient.Shell(serial, "settings", "put", "system", "font_scale", strconv.FormatFloat(scale, 'f', -1, 64))
+		return err
+	}
+}
+
+// setDisplayDensity returns a post-boot action which overrides the display density of the running device.
+func setDisplayDensity(density int) func(adbClient emulatormanager.ADB, serial string) error {
+	return func(adbClient emulatormanager.ADB, serial string) error {
+		_, err := adbClient.Shell(serial, "wm", "density", strconv.Itoa(density))
+		return err
+	}
+}
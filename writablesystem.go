@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-avd-manager/emulatormanager"
+)
+
+// prepareWritableSystem makes the system partition of the device writable (adb root, disable-verity and the reboot it requires, remount),
+// then runs the modification script and reboots the device, so the modified system is loaded before the tests.
+func prepareWritableSystem(ctx context.Context, adbClient emulatormanager.ADB, serial, script string, boot emulatormanager.BootCompletion) error {
+	if err := emulatormanager.WaitForBootComplete(ctx, adbClient, serial, boot); err != nil {
+		return err
+	}
+	if err := enableRootAccess(ctx, adbClient, serial, boot); err != nil {
+		return err
+	}
+	if script == "" {
+		return nil
+	}
+
+	if err := runSystemModificationScript(ctx, adbClient, serial, script); err != nil {
+		return fmt.Errorf("system modification script failed: %s", err)
+	}
+	return rebootDevice(ctx, adbClient, serial, boot)
+}
+
+// runSystemModificationScript runs the script with bash, ANDROID_SERIAL selects the device for the adb commands of the script.
+func runSystemModificationScript(ctx context.Context, adbClient emulatormanager.ADB, serial, script string) error {
+	cmd := command.NewWithCmd(exec.CommandContext(ctx, "bash", "-c", script)).
+		SetStdout(os.Stdout).
+		SetStderr(os.Stderr).
+		AppendEnvs("ANDROID_SERIAL="+serial, "ADB="+adbClient.Path)
+	log.Printf("Running system modification script (%s)", serial)
+	return cmd.Run()
+}